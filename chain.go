@@ -1,5 +1,11 @@
 package iptables
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Chain represents the default chains
 type Chain string
 
@@ -10,3 +16,215 @@ const (
 	ChainPreRouting  Chain = "PREROUTING"
 	ChainPostRouting Chain = "POSTROUTING"
 )
+
+var (
+	errNoChain = fmt.Errorf("no chain by that name was found")
+)
+
+func CreateChain(table Table, chain Chain) error {
+	return createChain(IPv4, table, chain)
+}
+
+func Create6Chain(table Table, chain Chain) error {
+	return createChain(IPv6, table, chain)
+}
+
+func createChain(ver IPVer, table Table, chain Chain) error {
+	tableLock.Lock()
+	defer tableLock.Unlock()
+	return chainCommand(ver, table, CmdNewChain, string(chain))
+}
+
+// EnsureChain creates the chain if it doesn't already exist
+func EnsureChain(table Table, chain Chain) error {
+	return ensureChain(IPv4, table, chain)
+}
+
+// Ensure6Chain creates the chain if it doesn't already exist
+func Ensure6Chain(table Table, chain Chain) error {
+	return ensureChain(IPv6, table, chain)
+}
+
+func ensureChain(ver IPVer, table Table, chain Chain) error {
+	tableLock.Lock()
+	defer tableLock.Unlock()
+	_, err := chainReferences(ver, table, chain)
+	if err == nil {
+		return nil
+	} else if err != errNoChain {
+		return err
+	}
+	return chainCommand(ver, table, CmdNewChain, string(chain))
+}
+
+// DeleteChain deletes the chain. The chain must be empty and chains that are still referenced by other rules are
+// not deleted
+func DeleteChain(table Table, chain Chain) error {
+	return deleteChain(IPv4, table, chain, false)
+}
+
+// Delete6Chain deletes the chain. The chain must be empty and chains that are still referenced by other rules are
+// not deleted
+func Delete6Chain(table Table, chain Chain) error {
+	return deleteChain(IPv6, table, chain, false)
+}
+
+// EnsureChainDeleted flushes and deletes the chain if it exists. Chains that are still referenced by other rules
+// are not deleted
+func EnsureChainDeleted(table Table, chain Chain) error {
+	return deleteChain(IPv4, table, chain, true)
+}
+
+// Ensure6ChainDeleted flushes and deletes the chain if it exists. Chains that are still referenced by other rules
+// are not deleted
+func Ensure6ChainDeleted(table Table, chain Chain) error {
+	return deleteChain(IPv6, table, chain, true)
+}
+
+func deleteChain(ver IPVer, table Table, chain Chain, ensure bool) error {
+	tableLock.Lock()
+	defer tableLock.Unlock()
+	references, err := chainReferences(ver, table, chain)
+	if err == errNoChain && ensure {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if references > 0 {
+		return fmt.Errorf("chain %s in table %s is still referenced by %d rule(s)", chain, table, references)
+	}
+	if ensure {
+		err = chainCommand(ver, table, CmdFlush, string(chain))
+		if err != nil {
+			return err
+		}
+	}
+	return chainCommand(ver, table, CmdDeleteChain, string(chain))
+}
+
+func FlushChain(table Table, chain Chain) error {
+	return flushChain(IPv4, table, chain)
+}
+
+func Flush6Chain(table Table, chain Chain) error {
+	return flushChain(IPv6, table, chain)
+}
+
+func flushChain(ver IPVer, table Table, chain Chain) error {
+	tableLock.Lock()
+	defer tableLock.Unlock()
+	return chainCommand(ver, table, CmdFlush, string(chain))
+}
+
+func RenameChain(table Table, chain Chain, newName Chain) error {
+	return renameChain(IPv4, table, chain, newName)
+}
+
+func Rename6Chain(table Table, chain Chain, newName Chain) error {
+	return renameChain(IPv6, table, chain, newName)
+}
+
+func renameChain(ver IPVer, table Table, chain Chain, newName Chain) error {
+	tableLock.Lock()
+	defer tableLock.Unlock()
+	return chainCommand(ver, table, CmdRenameChain, string(chain), string(newName))
+}
+
+// SetPolicy sets the policy of a built-in chain. Setting the policy is idempotent
+func SetPolicy(table Table, chain Chain, policy string) error {
+	return setPolicy(IPv4, table, chain, policy)
+}
+
+// Set6Policy sets the policy of a built-in chain. Setting the policy is idempotent
+func Set6Policy(table Table, chain Chain, policy string) error {
+	return setPolicy(IPv6, table, chain, policy)
+}
+
+func setPolicy(ver IPVer, table Table, chain Chain, policy string) error {
+	tableLock.Lock()
+	defer tableLock.Unlock()
+	return chainCommand(ver, table, CmdPolicy, string(chain), policy)
+}
+
+func ZeroChain(table Table, chain Chain) error {
+	return zeroChain(IPv4, table, chain)
+}
+
+func Zero6Chain(table Table, chain Chain) error {
+	return zeroChain(IPv6, table, chain)
+}
+
+func zeroChain(ver IPVer, table Table, chain Chain) error {
+	tableLock.Lock()
+	defer tableLock.Unlock()
+	return chainCommand(ver, table, CmdZero, string(chain))
+}
+
+func ChainExists(table Table, chain Chain) (bool, error) {
+	return chainExists(IPv4, table, chain)
+}
+
+func Chain6Exists(table Table, chain Chain) (bool, error) {
+	return chainExists(IPv6, table, chain)
+}
+
+func chainExists(ver IPVer, table Table, chain Chain) (bool, error) {
+	_, err := chainReferences(ver, table, chain)
+	if err == errNoChain {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ChainReferences returns the number of rules that jump or goto the chain
+func ChainReferences(table Table, chain Chain) (int, error) {
+	return chainReferences(IPv4, table, chain)
+}
+
+// Chain6References returns the number of rules that jump or goto the chain
+func Chain6References(table Table, chain Chain) (int, error) {
+	return chainReferences(IPv6, table, chain)
+}
+
+func chainReferences(ver IPVer, table Table, chain Chain) (references int, err error) {
+	ipt, err := iptablesCommand(ver)
+	if err != nil {
+		return 0, err
+	}
+	listCmd := fmt.Sprintf("%s -t %s -nL %s", ipt, table, chain)
+	result, err := run(listCmd)
+	if err != nil {
+		if strings.Contains(result, "No chain/target/match by that name") {
+			return 0, errNoChain
+		}
+		return 0, fmt.Errorf("failed to list chain %s: %w: %s", chain, err, strings.TrimSpace(result))
+	}
+
+	// The header of a user defined chain looks like 'Chain NAME (2 references)' while built-in chains show their
+	// policy instead and can't be referenced
+	header := strings.Split(result, "\n")[0]
+	start := strings.Index(header, "(")
+	if start == -1 {
+		return 0, nil
+	}
+	fields := strings.Fields(strings.Trim(header[start:], "()"))
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "reference") {
+		return 0, nil
+	}
+	return strconv.Atoi(fields[0])
+}
+
+func chainCommand(ver IPVer, table Table, cmd Cmd, args ...string) error {
+	ipt, err := iptablesCommand(ver)
+	if err != nil {
+		return err
+	}
+	command := fmt.Sprintf("%s -t %s --%s %s", ipt, table, cmd, strings.Join(args, " "))
+	result, err := run(command)
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w: %s", cmd, strings.Join(args, " "), err, strings.TrimSpace(result))
+	}
+	return nil
+}
@@ -9,8 +9,12 @@ import (
 
 func main() {
 
-	mo := iptables.NewMatchGeneric("physdev", "physdev-out", "eth-up", false)
-	mi := iptables.NewMatchGeneric("physdev", "physdev-in", "eth-dn", false)
+	mo := &iptables.MatchPhysDev{
+		Out: "eth-up",
+	}
+	mi := &iptables.MatchPhysDev{
+		In: "eth-dn",
+	}
 	r := &iptables.Rule{
 		Id:           "rule-2-ul-0",
 		Name:         "super-test-ul-0",
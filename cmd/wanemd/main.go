@@ -8,7 +8,9 @@ import (
 func main() {
 	// Test to make sure that the format that wanemd needs is fully supported
 	// c.IpTablesFmtString = "/sbin/iptables -A rule-%d -m physdev --physdev-out %s -o %s %s -j MARK --set-mark %d" // [rule.Id, device, bridge, filter, handle]
-	mo := iptables.NewMatchGeneric("physdev", "physdev-out", "eth-up", false)
+	mo := &iptables.MatchPhysDev{
+		Out: "eth-up",
+	}
 
 	r := &iptables.Rule{
 		Id:           "rule-123",
@@ -1,7 +1,39 @@
 package iptables
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Counter is a helper type that wraps the packet and byte counters
 type Counter struct {
-	Packets int `json:"packets,omitempty" yaml:"packets" xml:"packets"`
-	Bytes   int `json:"bytes,omitempty" yaml:"bytes" xml:"bytes"`
+	Packets uint64 `json:"packets,omitempty" yaml:"packets" xml:"packets"`
+	Bytes   uint64 `json:"bytes,omitempty" yaml:"bytes" xml:"bytes"`
+}
+
+// ChainPolicy holds the policy of a built-in chain along with the packet and byte counters of the policy
+type ChainPolicy struct {
+	IpVersion IPVer   `json:"ip_version,omitempty" yaml:"ip_version" xml:"ip_version"`
+	Table     Table   `json:"table,omitempty" yaml:"table" xml:"table"`
+	Chain     Chain   `json:"chain,omitempty" yaml:"chain" xml:"chain"`
+	Policy    string  `json:"policy,omitempty" yaml:"policy" xml:"policy"`
+	Counters  Counter `json:"counters,omitempty" yaml:"counters" xml:"counters"`
+}
+
+// ParseCounter parses the [packets:bytes] format used by iptables-save
+func ParseCounter(value string) (counter Counter, err error) {
+	parts := strings.Split(strings.Trim(value, "[]"), ":")
+	if len(parts) != 2 {
+		return counter, fmt.Errorf("invalid counter format. expected [packets:bytes] got %s", value)
+	}
+	counter.Packets, err = strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return counter, fmt.Errorf("invalid packet counter %s: %v", parts[0], err)
+	}
+	counter.Bytes, err = strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return counter, fmt.Errorf("invalid byte counter %s: %v", parts[1], err)
+	}
+	return counter, nil
 }
@@ -0,0 +1,279 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	executor     Executor = &ExecExecutor{}
+	executorLock          = sync.RWMutex{}
+)
+
+// Executor is the interface used to run every command this package issues. Replacing the default executor makes it
+// possible to add timeouts, observe what would be run, or use the package against canned ip(6)tables output.
+// Executors are assumed to change the local system and need root. An executor that doesn't can opt out of the root
+// check by implementing a RequiresRoot() bool method that returns false
+type Executor interface {
+	// Execute runs the command line and returns the combined output of the command
+	Execute(command string) (output string, err error)
+	// ExecuteWithInput runs the command line with input written to its stdin and returns the combined output
+	ExecuteWithInput(command string, input string) (output string, err error)
+	// LookPath resolves the full path of the named binary
+	LookPath(name string) (path string, err error)
+}
+
+// SetExecutor replaces the executor used by the package. Passing nil restores the default ExecExecutor
+func SetExecutor(e Executor) {
+	executorLock.Lock()
+	defer executorLock.Unlock()
+	if e == nil {
+		e = &ExecExecutor{}
+	}
+	executor = e
+}
+
+// GetExecutor returns the executor currently used by the package
+func GetExecutor() Executor {
+	executorLock.RLock()
+	defer executorLock.RUnlock()
+	return executor
+}
+
+// run executes the command line using the current executor. Failures caused by xtables lock contention are
+// returned as a LockError
+func run(command string) (output string, err error) {
+	output, err = GetExecutor().Execute(command)
+	return output, lockError(command, output, err)
+}
+
+// runWithInput executes the command line using the current executor and feeds input to its stdin. Failures caused
+// by xtables lock contention are returned as a LockError
+func runWithInput(command string, input string) (output string, err error) {
+	output, err = GetExecutor().ExecuteWithInput(command, input)
+	return output, lockError(command, output, err)
+}
+
+// requiresRoot returns true unless the current executor opted out of the root check
+func requiresRoot() bool {
+	return executorRequiresRoot(GetExecutor())
+}
+
+// executorRequiresRoot returns the result of the RequiresRoot method of the executor, or true when the executor
+// doesn't have one
+func executorRequiresRoot(e Executor) bool {
+	if v, ok := e.(interface{ RequiresRoot() bool }); ok {
+		return v.RequiresRoot()
+	}
+	return true
+}
+
+// ExecExecutor runs commands on the local system
+type ExecExecutor struct {
+	// Timeout is the maximum amount of time a single command is allowed to run, zero means no timeout
+	Timeout time.Duration
+}
+
+func (e *ExecExecutor) Execute(command string) (output string, err error) {
+	return e.ExecuteWithInput(command, "")
+}
+
+func (e *ExecExecutor) ExecuteWithInput(command string, input string) (output string, err error) {
+	parts, err := splitArgs(command)
+	if err != nil {
+		return "", err
+	}
+	for i := range parts {
+		parts[i] = unquoteArg(parts[i])
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("no command to execute")
+	}
+
+	ctx := context.Background()
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(out), fmt.Errorf("command timed out after %s: %s", e.Timeout, command)
+	}
+	return string(out), err
+}
+
+func (e *ExecExecutor) LookPath(name string) (path string, err error) {
+	return exec.LookPath(name)
+}
+
+// ExecRecord holds a single command along with the output and error it produced
+type ExecRecord struct {
+	Command string `json:"command" yaml:"command" xml:"command"`
+	Input   string `json:"input,omitempty" yaml:"input" xml:"input"`
+	Output  string `json:"output,omitempty" yaml:"output" xml:"output"`
+	Error   string `json:"error,omitempty" yaml:"error" xml:"error"`
+}
+
+// RecordingExecutor wraps another executor and records every command it runs along with the results. The records
+// can later be loaded into a FakeExecutor to replay a session
+type RecordingExecutor struct {
+	Executor Executor
+	records  []ExecRecord
+	lock     sync.Mutex
+}
+
+func (e *RecordingExecutor) Execute(command string) (output string, err error) {
+	return e.ExecuteWithInput(command, "")
+}
+
+func (e *RecordingExecutor) ExecuteWithInput(command string, input string) (output string, err error) {
+	output, err = e.Executor.ExecuteWithInput(command, input)
+	record := ExecRecord{
+		Command: command,
+		Input:   input,
+		Output:  output,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	e.lock.Lock()
+	e.records = append(e.records, record)
+	e.lock.Unlock()
+	return output, err
+}
+
+func (e *RecordingExecutor) LookPath(name string) (path string, err error) {
+	return e.Executor.LookPath(name)
+}
+
+func (e *RecordingExecutor) Unwrap() Executor {
+	return e.Executor
+}
+
+// RequiresRoot returns if the wrapped executor needs root
+func (e *RecordingExecutor) RequiresRoot() bool {
+	return executorRequiresRoot(e.Executor)
+}
+
+// Records returns a copy of all the commands recorded so far
+func (e *RecordingExecutor) Records() []ExecRecord {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	records := make([]ExecRecord, len(e.records))
+	copy(records, e.records)
+	return records
+}
+
+// NewFakeExecutor returns a FakeExecutor that replays the passed in records
+func NewFakeExecutor(records ...ExecRecord) *FakeExecutor {
+	e := &FakeExecutor{}
+	e.Load(records...)
+	return e
+}
+
+// FakeExecutor never runs anything. It records every command it is asked to run and answers with canned
+// responses. Responses registered for the same command are replayed in order and the last one is repeated.
+// Responses are matched on the command line only, the input of a command is recorded but not compared
+type FakeExecutor struct {
+	// Default is returned for any command that doesn't have a canned response
+	Default   ExecRecord
+	responses map[string][]ExecRecord
+	executed  []ExecRecord
+	lock      sync.Mutex
+}
+
+// Load registers the passed in records as canned responses
+func (e *FakeExecutor) Load(records ...ExecRecord) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.responses == nil {
+		e.responses = make(map[string][]ExecRecord)
+	}
+	for _, record := range records {
+		e.responses[record.Command] = append(e.responses[record.Command], record)
+	}
+}
+
+// Respond registers a canned response for the command. An empty errMsg means the command succeeds
+func (e *FakeExecutor) Respond(command string, output string, errMsg string) {
+	e.Load(ExecRecord{
+		Command: command,
+		Output:  output,
+		Error:   errMsg,
+	})
+}
+
+func (e *FakeExecutor) Execute(command string) (output string, err error) {
+	return e.ExecuteWithInput(command, "")
+}
+
+func (e *FakeExecutor) ExecuteWithInput(command string, input string) (output string, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.executed = append(e.executed, ExecRecord{Command: command, Input: input})
+
+	record := e.Default
+	if responses, ok := e.responses[command]; ok && len(responses) > 0 {
+		record = responses[0]
+		if len(responses) > 1 {
+			e.responses[command] = responses[1:]
+		}
+	}
+
+	if record.Error != "" {
+		return record.Output, fmt.Errorf("%s", record.Error)
+	}
+	return record.Output, nil
+}
+
+// LookPath returns the name unchanged so the generated commands don't depend on the local system
+func (e *FakeExecutor) LookPath(name string) (path string, err error) {
+	return name, nil
+}
+
+// RequiresRoot returns false as the fake executor never changes the local system
+func (e *FakeExecutor) RequiresRoot() bool {
+	return false
+}
+
+// Commands returns every command the executor has been asked to run
+func (e *FakeExecutor) Commands() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	commands := make([]string, 0, len(e.executed))
+	for _, record := range e.executed {
+		commands = append(commands, record.Command)
+	}
+	return commands
+}
+
+// Executed returns every command the executor has been asked to run along with the input passed to it
+func (e *FakeExecutor) Executed() []ExecRecord {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	executed := make([]ExecRecord, len(e.executed))
+	copy(executed, e.executed)
+	return executed
+}
+
+// Reset clears the recorded commands
+func (e *FakeExecutor) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.executed = nil
+}
+
+// String returns the recorded commands one per line
+func (e *FakeExecutor) String() string {
+	return strings.Join(e.Commands(), "\n")
+}
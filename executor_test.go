@@ -0,0 +1,115 @@
+package iptables
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useFakeExecutor installs a FakeExecutor loaded with the records for the duration of the test
+func useFakeExecutor(t *testing.T, records ...ExecRecord) *FakeExecutor {
+	t.Helper()
+	previous := GetExecutor()
+	fake := NewFakeExecutor(records...)
+	SetExecutor(fake)
+	t.Cleanup(func() {
+		SetExecutor(previous)
+	})
+	return fake
+}
+
+// changes returns the commands run by the fake executor without the 'ip(6)tables -t <table> -S' listings that are
+// used to look up rule ids and names
+func changes(fake *FakeExecutor) []ExecRecord {
+	records := make([]ExecRecord, 0)
+	for _, record := range fake.Executed() {
+		if !strings.HasSuffix(record.Command, " -S") {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestFakeExecutor(t *testing.T) {
+	fake := NewFakeExecutor(
+		ExecRecord{Command: "iptables -t filter -S", Output: "first"},
+		ExecRecord{Command: "iptables -t filter -S", Output: "second"},
+		ExecRecord{Command: "iptables -t nat -S", Output: "failed", Error: "exit status 1"},
+	)
+	fake.Default = ExecRecord{Output: "default"}
+
+	tests := []struct {
+		command string
+		input   string
+		output  string
+		err     string
+	}{
+		{command: "iptables -t filter -S", output: "first"},
+		{command: "iptables -t filter -S", output: "second"},
+		{command: "iptables -t filter -S", output: "second"},
+		{command: "iptables -t nat -S", output: "failed", err: "exit status 1"},
+		{command: "iptables-restore --noflush", input: "*filter\nCOMMIT\n", output: "default"},
+	}
+	for _, tt := range tests {
+		output, err := fake.ExecuteWithInput(tt.command, tt.input)
+		if output != tt.output {
+			t.Errorf("%s: got output %q, want %q", tt.command, output, tt.output)
+		}
+		if (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.command, err, tt.err)
+		}
+	}
+
+	executed := fake.Executed()
+	if len(executed) != len(tests) {
+		t.Fatalf("got %d executed commands, want %d", len(executed), len(tests))
+	}
+	for idx, tt := range tests {
+		if executed[idx].Command != tt.command || executed[idx].Input != tt.input {
+			t.Errorf("executed[%d] = %+v, want command %q with input %q", idx, executed[idx], tt.command, tt.input)
+		}
+	}
+}
+
+func TestRecordingExecutor(t *testing.T) {
+	fake := NewFakeExecutor(ExecRecord{Command: "iptables -t filter -S", Output: "-P INPUT ACCEPT"})
+	recorder := &RecordingExecutor{Executor: fake}
+	if _, err := recorder.Execute("iptables -t filter -S"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []ExecRecord{{Command: "iptables -t filter -S", Output: "-P INPUT ACCEPT"}}
+	if got := recorder.Records(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got records %+v, want %+v", got, want)
+	}
+
+	// The records replay the session on a new fake executor
+	replay := NewFakeExecutor(recorder.Records()...)
+	if output, _ := replay.Execute("iptables -t filter -S"); output != "-P INPUT ACCEPT" {
+		t.Errorf("got replayed output %q, want %q", output, "-P INPUT ACCEPT")
+	}
+}
+
+func TestRequiresRoot(t *testing.T) {
+	fake := NewFakeExecutor()
+	tests := []struct {
+		name     string
+		executor Executor
+		want     bool
+	}{
+		{name: "exec", executor: &ExecExecutor{}, want: true},
+		{name: "fake", executor: fake},
+		{name: "recording exec", executor: &RecordingExecutor{Executor: &ExecExecutor{}}, want: true},
+		{name: "recording fake", executor: &RecordingExecutor{Executor: fake}},
+		{name: "retry fake", executor: &RetryExecutor{Executor: fake}},
+		{name: "custom", executor: struct{ Executor }{fake}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeExecutor(t)
+			SetExecutor(tt.executor)
+			if got := requiresRoot(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,8 +2,4 @@ module github.com/BGrewell/go-iptables
 
 go 1.16
 
-require (
-	github.com/BGrewell/go-conversions v0.0.0-20210512190113-ef0a66d37f0f // indirect
-	github.com/BGrewell/go-execute v0.0.0-20210721193233-180d8f149d59
-	github.com/google/uuid v1.3.0
-)
+require github.com/google/uuid v1.3.0
@@ -0,0 +1,173 @@
+package iptables
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	lockWait         time.Duration
+	lockWaitInterval time.Duration
+	lockWaitLock     = sync.RWMutex{}
+
+	lockContentionMessages = []string{
+		"Another app is currently holding the xtables lock",
+		"Resource temporarily unavailable",
+	}
+)
+
+// SetLockWait configures how long generated ip(6)tables and ip(6)tables-restore commands wait for the xtables lock
+// using --wait. A wait of zero disables waiting and a negative wait waits indefinitely. The interval is passed as
+// --wait-interval and controls how often the lock is polled, zero leaves the iptables default in place
+func SetLockWait(wait time.Duration, interval time.Duration) {
+	lockWaitLock.Lock()
+	defer lockWaitLock.Unlock()
+	lockWait = wait
+	lockWaitInterval = interval
+}
+
+// GetLockWait returns the currently configured lock wait and interval
+func GetLockWait() (wait time.Duration, interval time.Duration) {
+	lockWaitLock.RLock()
+	defer lockWaitLock.RUnlock()
+	return lockWait, lockWaitInterval
+}
+
+// lockArgs returns the --wait and --wait-interval arguments for the configured lock wait
+func lockArgs() []string {
+	wait, interval := GetLockWait()
+	args := make([]string, 0)
+	if wait == 0 {
+		return args
+	}
+
+	if wait < 0 {
+		args = append(args, "--wait")
+	} else {
+		seconds := int((wait + time.Second - 1) / time.Second)
+		args = append(args, "--wait", strconv.Itoa(seconds))
+	}
+
+	if interval > 0 {
+		args = append(args, "--wait-interval", strconv.FormatInt(interval.Microseconds(), 10))
+	}
+
+	return args
+}
+
+// iptablesCommand returns the ip(6)tables binary followed by the configured lock arguments
+func iptablesCommand(ver IPVer) (cmd string, err error) {
+	ipt, err := GetIptablesBinaryPath(string(ver))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(append([]string{ipt}, lockArgs()...), " "), nil
+}
+
+// iptablesRestoreCommand returns the ip(6)tables-restore binary followed by the configured lock arguments
+func iptablesRestoreCommand(ver IPVer) (cmd string, err error) {
+	restore, err := GetIptablesRestoreBinaryPath(string(ver))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(append([]string{restore}, lockArgs()...), " "), nil
+}
+
+// LockError is returned when a command fails because another process is holding the xtables lock. The command can
+// safely be retried
+type LockError struct {
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e *LockError) Error() string {
+	return fmt.Sprintf("xtables lock is held by another process: %v", e.Err)
+}
+
+func (e *LockError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable always returns true as lock contention is a temporary condition
+func (e *LockError) Retryable() bool {
+	return true
+}
+
+// IsLockError returns true if the error, or any error it wraps, is a LockError
+func IsLockError(err error) bool {
+	var lerr *LockError
+	return errors.As(err, &lerr)
+}
+
+// lockError returns a LockError if the output of the command shows it failed due to lock contention otherwise the
+// error is returned unchanged
+func lockError(command string, output string, err error) error {
+	if err == nil || IsLockError(err) {
+		return err
+	}
+	for _, message := range lockContentionMessages {
+		if strings.Contains(output, message) || strings.Contains(err.Error(), message) {
+			return &LockError{
+				Command: command,
+				Output:  output,
+				Err:     err,
+			}
+		}
+	}
+	return err
+}
+
+// RetryPolicy controls how commands that failed due to xtables lock contention are retried
+type RetryPolicy struct {
+	// Attempts is the total number of times a command is run, values less than 1 run the command once
+	Attempts int
+	// Backoff is the delay before the first retry, it is doubled after every retry
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries, zero means no cap
+	MaxBackoff time.Duration
+}
+
+// RetryExecutor wraps another executor and retries commands that fail because of xtables lock contention
+type RetryExecutor struct {
+	Executor Executor
+	Policy   RetryPolicy
+}
+
+func (e *RetryExecutor) Execute(command string) (output string, err error) {
+	return e.ExecuteWithInput(command, "")
+}
+
+func (e *RetryExecutor) ExecuteWithInput(command string, input string) (output string, err error) {
+	backoff := e.Policy.Backoff
+	for attempt := 1; ; attempt++ {
+		output, err = e.Executor.ExecuteWithInput(command, input)
+		err = lockError(command, output, err)
+		if err == nil || !IsLockError(err) || attempt >= e.Policy.Attempts {
+			return output, err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if e.Policy.MaxBackoff > 0 && backoff > e.Policy.MaxBackoff {
+			backoff = e.Policy.MaxBackoff
+		}
+	}
+}
+
+func (e *RetryExecutor) LookPath(name string) (path string, err error) {
+	return e.Executor.LookPath(name)
+}
+
+func (e *RetryExecutor) Unwrap() Executor {
+	return e.Executor
+}
+
+// RequiresRoot returns if the wrapped executor needs root
+func (e *RetryExecutor) RequiresRoot() bool {
+	return executorRequiresRoot(e.Executor)
+}
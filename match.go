@@ -1,5 +1,12 @@
 package iptables
 
+import (
+	"fmt"
+	"strings"
+)
+
+// Match is the interface for the match extensions. A match is made up of the name of the module and an ordered
+// list of options, each of which can be negated. Option, Value and Negated refer to the first option of the match
 type Match interface {
 	Name() string
 	SetName(name string) error
@@ -9,6 +16,131 @@ type Match interface {
 	SetValue(value string) error
 	Negated() bool
 	SetNegated(negated bool)
+	Options() []MatchOption
+	Parse(options []MatchOption) error
 	String() string
 	Validate(rule Rule) error
-}
\ No newline at end of file
+}
+
+// MatchOption is a single option of a match. Options that don't take a value have an empty value
+type MatchOption struct {
+	Option  string `json:"option" yaml:"option" xml:"option"`
+	Value   string `json:"value,omitempty" yaml:"value" xml:"value"`
+	Negated bool   `json:"negated,omitempty" yaml:"negated" xml:"negated"`
+}
+
+func (o MatchOption) String() string {
+	if o.Value == "" {
+		return fmt.Sprintf("%s--%s", GetNegatedPattern(o.Negated), o.Option)
+	}
+	return fmt.Sprintf("%s--%s %s", GetNegatedPattern(o.Negated), o.Option, o.Value)
+}
+
+var (
+	// matchParsers maps the name of a match module to a constructor for the typed match that is produced when a
+	// rule using the module is parsed. Modules that aren't listed are parsed into a MatchGeneric
+	matchParsers = map[string]func() Match{
+		matchConntrackName: func() Match { return &MatchConntrack{} },
+		matchStateName:     func() Match { return &MatchConntrack{UseStateModule: true} },
+		matchSetName:       func() Match { return &MatchSet{} },
+		matchLimitName:     func() Match { return &MatchLimit{} },
+		matchHashLimitName: func() Match { return &MatchHashLimit{} },
+		matchConnLimitName: func() Match { return &MatchConnLimit{} },
+		matchMarkName:      func() Match { return &MatchMark{} },
+		matchConnMarkName:  func() Match { return &MatchConnMark{} },
+		matchPhysDevName:   func() Match { return &MatchPhysDev{} },
+		matchTimeName:      func() Match { return &MatchTime{} },
+		matchStringName:    func() Match { return &MatchString{} },
+		matchU32Name:       func() Match { return &MatchU32{} },
+		matchBPFName:       func() Match { return &MatchBPF{} },
+		matchOwnerName:     func() Match { return &MatchOwner{} },
+		matchCgroupName:    func() Match { return &MatchCgroup{} },
+		matchSocketName:    func() Match { return &MatchSocket{} },
+		matchRecentName:    func() Match { return &MatchRecent{} },
+		matchAddrTypeName:  func() Match { return &MatchAddrType{} },
+		matchPktTypeName:   func() Match { return &MatchPktType{} },
+		matchIpRangeName:   func() Match { return &MatchIpRange{} },
+		matchMacName:       func() Match { return &MatchMac{} },
+		matchICMPName:      func() Match { return &MatchICMP{} },
+		matchICMPv6Name:    func() Match { return &MatchICMPv6{} },
+		matchTCPMSSName:    func() Match { return &MatchTCPMSS{} },
+	}
+)
+
+// newMatch returns an empty match for the named module
+func newMatch(name string) Match {
+	if constructor, ok := matchParsers[name]; ok {
+		return constructor()
+	}
+	return &MatchGeneric{name: name}
+}
+
+// matchString renders a match as --match <name> followed by each of its options
+func matchString(m Match) string {
+	parts := []string{fmt.Sprintf("--match %s", m.Name())}
+	for _, option := range m.Options() {
+		parts = append(parts, option.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// firstOption returns the first option of the match or an empty option if the match has no options
+func firstOption(m Match) MatchOption {
+	options := m.Options()
+	if len(options) == 0 {
+		return MatchOption{}
+	}
+	return options[0]
+}
+
+// collectMatchOptions reads all of the consecutive [!] --option [value...] entries starting at idx. Options that
+// don't take a value have an empty value. The index of the first field that isn't part of the match is returned
+func collectMatchOptions(fields []string, idx int) (options []MatchOption, next int) {
+	options = make([]MatchOption, 0)
+	for idx < len(fields) {
+		negated := false
+		if fields[idx] == "!" && idx+1 < len(fields) && strings.HasPrefix(fields[idx+1], "--") {
+			negated = true
+			idx++
+		}
+		if !strings.HasPrefix(fields[idx], "--") {
+			if negated {
+				idx-- // the negation belongs to whatever follows the match
+			}
+			break
+		}
+
+		option := MatchOption{
+			Option:  strings.TrimPrefix(fields[idx], "--"),
+			Negated: negated,
+		}
+		idx++
+
+		values := make([]string, 0)
+		for idx < len(fields) && !strings.HasPrefix(fields[idx], "-") && fields[idx] != "!" {
+			values = append(values, fields[idx])
+			idx++
+		}
+		option.Value = strings.Join(values, " ")
+		options = append(options, option)
+	}
+	return options, idx
+}
+
+// validateMatchChain returns an error if the rule is on a built-in chain other than the allowed chains. Rules on
+// user defined chains are accepted as the chains they are jumped to from aren't known
+func validateMatchChain(kind string, rule Rule, allowed ...Chain) error {
+	switch rule.Chain {
+	case ChainInput, ChainOutput, ChainForward, ChainPreRouting, ChainPostRouting:
+	default:
+		return nil
+	}
+	names := make([]string, 0)
+	for _, chain := range allowed {
+		if rule.Chain == chain {
+			return nil
+		}
+		names = append(names, string(chain))
+	}
+	return fmt.Errorf("%s is only valid on the %s chains", kind, strings.Join(names, ", "))
+}
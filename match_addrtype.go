@@ -0,0 +1,173 @@
+package iptables
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	matchAddrTypeName = "addrtype"
+)
+
+// AddrType is the routing type of an address
+type AddrType string
+
+const (
+	AddrTypeUnspec      AddrType = "UNSPEC"
+	AddrTypeUnicast     AddrType = "UNICAST"
+	AddrTypeLocal       AddrType = "LOCAL"
+	AddrTypeBroadcast   AddrType = "BROADCAST"
+	AddrTypeAnycast     AddrType = "ANYCAST"
+	AddrTypeMulticast   AddrType = "MULTICAST"
+	AddrTypeBlackhole   AddrType = "BLACKHOLE"
+	AddrTypeUnreachable AddrType = "UNREACHABLE"
+	AddrTypeProhibit    AddrType = "PROHIBIT"
+	AddrTypeThrow       AddrType = "THROW"
+	AddrTypeNat         AddrType = "NAT"
+	AddrTypeXResolve    AddrType = "XRESOLVE"
+)
+
+var (
+	addrTypes = []AddrType{AddrTypeUnspec, AddrTypeUnicast, AddrTypeLocal, AddrTypeBroadcast, AddrTypeAnycast, AddrTypeMulticast, AddrTypeBlackhole, AddrTypeUnreachable, AddrTypeProhibit, AddrTypeThrow, AddrTypeNat, AddrTypeXResolve}
+	// addr6Types are the types the kernel accepts in an IPv6 rule. IPv6 has no broadcast addresses, and blackhole
+	// routes and the types from prohibit on can't be matched for IPv6 addresses
+	addr6Types = []AddrType{AddrTypeUnspec, AddrTypeUnicast, AddrTypeLocal, AddrTypeAnycast, AddrTypeMulticast, AddrTypeUnreachable}
+)
+
+// MatchAddrType matches on the routing type of the source and/or destination address. LimitIfaceIn and
+// LimitIfaceOut only consider addresses of the interface the packet arrived on or is leaving through
+type MatchAddrType struct {
+	SrcTypes        []AddrType `json:"src_types,omitempty" yaml:"src_types" xml:"src_types"`
+	SrcTypesNegated bool       `json:"src_types_negated,omitempty" yaml:"src_types_negated" xml:"src_types_negated"`
+	DstTypes        []AddrType `json:"dst_types,omitempty" yaml:"dst_types" xml:"dst_types"`
+	DstTypesNegated bool       `json:"dst_types_negated,omitempty" yaml:"dst_types_negated" xml:"dst_types_negated"`
+	LimitIfaceIn    bool       `json:"limit_iface_in,omitempty" yaml:"limit_iface_in" xml:"limit_iface_in"`
+	LimitIfaceOut   bool       `json:"limit_iface_out,omitempty" yaml:"limit_iface_out" xml:"limit_iface_out"`
+}
+
+func (m MatchAddrType) Name() string {
+	return matchAddrTypeName
+}
+
+func (m *MatchAddrType) SetName(name string) error {
+	return fmt.Errorf("addrtype match doesn't support setting the name")
+}
+
+// Option returns the first option that is set on the match
+func (m MatchAddrType) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchAddrType) SetOption(option string) error {
+	return fmt.Errorf("addrtype match doesn't support setting the option")
+}
+
+// Value returns the value of the first option that is set on the match
+func (m MatchAddrType) Value() string {
+	return firstOption(&m).Value
+}
+
+func (m *MatchAddrType) SetValue(value string) error {
+	return fmt.Errorf("addrtype match doesn't support setting the value")
+}
+
+// Negated returns if the first option that is set on the match is negated
+func (m MatchAddrType) Negated() bool {
+	return firstOption(&m).Negated
+}
+
+// SetNegated does nothing as each option is negated on its own, use the negated fields instead
+func (m *MatchAddrType) SetNegated(negated bool) {
+
+}
+
+// Options returns the options of the match in the order iptables prints them
+func (m MatchAddrType) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if len(m.SrcTypes) > 0 {
+		options = append(options, MatchOption{Option: "src-type", Value: joinAddrTypes(m.SrcTypes), Negated: m.SrcTypesNegated})
+	}
+	if len(m.DstTypes) > 0 {
+		options = append(options, MatchOption{Option: "dst-type", Value: joinAddrTypes(m.DstTypes), Negated: m.DstTypesNegated})
+	}
+	if m.LimitIfaceIn {
+		options = append(options, MatchOption{Option: "limit-iface-in"})
+	}
+	if m.LimitIfaceOut {
+		options = append(options, MatchOption{Option: "limit-iface-out"})
+	}
+	return options
+}
+
+func (m *MatchAddrType) Parse(options []MatchOption) error {
+	for _, option := range options {
+		switch option.Option {
+		case "src-type":
+			m.SrcTypes = splitAddrTypes(option.Value)
+			m.SrcTypesNegated = option.Negated
+		case "dst-type":
+			m.DstTypes = splitAddrTypes(option.Value)
+			m.DstTypesNegated = option.Negated
+		case "limit-iface-in":
+			m.LimitIfaceIn = true
+		case "limit-iface-out":
+			m.LimitIfaceOut = true
+		default:
+			return fmt.Errorf("unsupported addrtype option --%s", option.Option)
+		}
+	}
+	return nil
+}
+
+func (m MatchAddrType) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchAddrType) Validate(rule Rule) error {
+	if len(m.SrcTypes) == 0 && len(m.DstTypes) == 0 {
+		return fmt.Errorf("addrtype match requires a source or destination type")
+	}
+	valid := addrTypes
+	if rule.IpVersion == IPv6 {
+		valid = addr6Types
+	}
+	for _, t := range append(append([]AddrType{}, m.SrcTypes...), m.DstTypes...) {
+		found := false
+		for _, v := range valid {
+			if t == v {
+				found = true
+			}
+		}
+		if !found && rule.IpVersion == IPv6 {
+			return fmt.Errorf("addrtype match type %s can't be used in an %s rule", t, IPv6)
+		} else if !found {
+			return fmt.Errorf("invalid addrtype match type %s", t)
+		}
+	}
+	if m.LimitIfaceIn && m.LimitIfaceOut {
+		return fmt.Errorf("addrtype match can't limit to both the input and output interface")
+	}
+	if m.LimitIfaceIn {
+		return validateMatchChain("addrtype match limit-iface-in", rule, ChainPreRouting, ChainInput, ChainForward)
+	}
+	if m.LimitIfaceOut {
+		return validateMatchChain("addrtype match limit-iface-out", rule, ChainOutput, ChainPostRouting, ChainForward)
+	}
+	return nil
+}
+
+func joinAddrTypes(types []AddrType) string {
+	parts := make([]string, 0)
+	for _, t := range types {
+		parts = append(parts, string(t))
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitAddrTypes(value string) []AddrType {
+	types := make([]AddrType, 0)
+	for _, t := range strings.Split(value, ",") {
+		types = append(types, AddrType(strings.ToUpper(t)))
+	}
+	return types
+}
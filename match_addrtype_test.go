@@ -0,0 +1,31 @@
+package iptables
+
+import (
+	"testing"
+)
+
+func TestMatchAddrTypeValidate(t *testing.T) {
+	tests := []struct {
+		ipVer   IPVer
+		typ     AddrType
+		wantErr bool
+	}{
+		{ipVer: IPv4, typ: AddrTypeBroadcast},
+		{ipVer: IPv4, typ: AddrTypeBlackhole},
+		{ipVer: IPv6, typ: AddrTypeLocal},
+		{ipVer: IPv6, typ: AddrTypeUnreachable},
+		{ipVer: IPv6, typ: AddrTypeBroadcast, wantErr: true},
+		{ipVer: IPv6, typ: AddrTypeBlackhole, wantErr: true},
+		{ipVer: IPv6, typ: AddrTypeProhibit, wantErr: true},
+		{ipVer: IPv6, typ: AddrTypeXResolve, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.ipVer)+" "+string(tt.typ), func(t *testing.T) {
+			m := &MatchAddrType{DstTypes: []AddrType{tt.typ}}
+			err := m.Validate(Rule{IpVersion: tt.ipVer})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
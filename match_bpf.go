@@ -0,0 +1,146 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	matchBPFName = "bpf"
+
+	// matchBPFMaxInstructions is the most instructions the kernel accepts in a bpf match
+	matchBPFMaxInstructions = 64
+)
+
+// BPFInstruction is a single classic BPF instruction as printed by nfbpf_compile and iptables
+type BPFInstruction struct {
+	Code uint16 `json:"code" yaml:"code" xml:"code"`
+	Jt   uint8  `json:"jt" yaml:"jt" xml:"jt"`
+	Jf   uint8  `json:"jf" yaml:"jf" xml:"jf"`
+	K    uint32 `json:"k" yaml:"k" xml:"k"`
+}
+
+// MatchBPF matches packets using a classic BPF program or an eBPF program pinned at ObjectPinned. Only one of
+// Instructions or ObjectPinned can be set
+type MatchBPF struct {
+	Instructions []BPFInstruction `json:"instructions,omitempty" yaml:"instructions" xml:"instructions"`
+	ObjectPinned string           `json:"object_pinned,omitempty" yaml:"object_pinned" xml:"object_pinned"`
+}
+
+// ParseBPFBytecode parses bytecode in the '<count>,<code> <jt> <jf> <k>,...' format produced by nfbpf_compile
+func ParseBPFBytecode(bytecode string) (instructions []BPFInstruction, err error) {
+	parts := strings.Split(strings.TrimSpace(bytecode), ",")
+	count, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid bpf bytecode length %s", parts[0])
+	}
+	if count != len(parts)-1 {
+		return nil, fmt.Errorf("bpf bytecode has %d instructions but a length of %d", len(parts)-1, count)
+	}
+
+	instructions = make([]BPFInstruction, 0, count)
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid bpf instruction '%s'. expected <code> <jt> <jf> <k>", part)
+		}
+		values := make([]uint64, 4)
+		for i, bits := range []int{16, 8, 8, 32} {
+			values[i], err = strconv.ParseUint(fields[i], 10, bits)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bpf instruction '%s': %v", part, err)
+			}
+		}
+		instructions = append(instructions, BPFInstruction{
+			Code: uint16(values[0]),
+			Jt:   uint8(values[1]),
+			Jf:   uint8(values[2]),
+			K:    uint32(values[3]),
+		})
+	}
+	return instructions, nil
+}
+
+// Bytecode returns the instructions in the format used by --bytecode
+func (m MatchBPF) Bytecode() string {
+	parts := []string{strconv.Itoa(len(m.Instructions))}
+	for _, i := range m.Instructions {
+		parts = append(parts, fmt.Sprintf("%d %d %d %d", i.Code, i.Jt, i.Jf, i.K))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (m MatchBPF) Name() string {
+	return matchBPFName
+}
+
+func (m *MatchBPF) SetName(name string) error {
+	return fmt.Errorf("bpf match doesn't support setting the name")
+}
+
+func (m MatchBPF) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchBPF) SetOption(option string) error {
+	return fmt.Errorf("bpf match doesn't support setting the option")
+}
+
+func (m MatchBPF) Value() string {
+	return firstOption(&m).Value
+}
+
+// SetValue sets the instructions from bytecode
+func (m *MatchBPF) SetValue(value string) (err error) {
+	m.Instructions, err = ParseBPFBytecode(value)
+	return err
+}
+
+func (m MatchBPF) Negated() bool {
+	return false
+}
+
+func (m *MatchBPF) SetNegated(negated bool) {
+
+}
+
+func (m MatchBPF) Options() []MatchOption {
+	if m.ObjectPinned != "" {
+		return []MatchOption{{Option: "object-pinned", Value: quoteArg(m.ObjectPinned)}}
+	}
+	return []MatchOption{{Option: "bytecode", Value: quoteString(m.Bytecode(), "")}}
+}
+
+func (m *MatchBPF) Parse(options []MatchOption) error {
+	for _, option := range options {
+		switch option.Option {
+		case "bytecode":
+			if err := m.SetValue(unquoteArg(option.Value)); err != nil {
+				return err
+			}
+		case "object-pinned":
+			m.ObjectPinned = unquoteArg(option.Value)
+		default:
+			return fmt.Errorf("unsupported bpf option --%s", option.Option)
+		}
+	}
+	return nil
+}
+
+func (m MatchBPF) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchBPF) Validate(rule Rule) error {
+	if (len(m.Instructions) == 0) == (m.ObjectPinned == "") {
+		return fmt.Errorf("bpf match requires either instructions or a pinned object")
+	}
+	if len(m.Instructions) > matchBPFMaxInstructions {
+		return fmt.Errorf("bpf match supports at most %d instructions", matchBPFMaxInstructions)
+	}
+	if m.ObjectPinned != "" && !strings.HasPrefix(m.ObjectPinned, "/") {
+		return fmt.Errorf("bpf match pinned object must be an absolute path")
+	}
+	return nil
+}
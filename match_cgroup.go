@@ -0,0 +1,104 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	matchCgroupName = "cgroup"
+)
+
+// NewMatchCgroup returns a cgroup match for the cgroup v2 path, for example system.slice/nginx.service
+func NewMatchCgroup(path string) *MatchCgroup {
+	return &MatchCgroup{
+		Path: path,
+	}
+}
+
+// MatchCgroup matches packets of sockets that belong to a cgroup. Path matches a cgroup v2 path relative to the
+// root of the hierarchy while ClassId matches the net_cls classid of a cgroup v1 hierarchy. Only one can be set
+type MatchCgroup struct {
+	Path           string `json:"path,omitempty" yaml:"path" xml:"path"`
+	PathNegated    bool   `json:"path_negated,omitempty" yaml:"path_negated" xml:"path_negated"`
+	ClassId        uint32 `json:"class_id,omitempty" yaml:"class_id" xml:"class_id"`
+	ClassIdNegated bool   `json:"class_id_negated,omitempty" yaml:"class_id_negated" xml:"class_id_negated"`
+}
+
+func (m MatchCgroup) Name() string {
+	return matchCgroupName
+}
+
+func (m *MatchCgroup) SetName(name string) error {
+	return fmt.Errorf("cgroup match doesn't support setting the name")
+}
+
+func (m MatchCgroup) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchCgroup) SetOption(option string) error {
+	return fmt.Errorf("cgroup match doesn't support setting the option")
+}
+
+func (m MatchCgroup) Value() string {
+	return firstOption(&m).Value
+}
+
+// SetValue sets the path
+func (m *MatchCgroup) SetValue(value string) error {
+	m.Path = value
+	return nil
+}
+
+func (m MatchCgroup) Negated() bool {
+	return firstOption(&m).Negated
+}
+
+// SetNegated sets if the path is negated
+func (m *MatchCgroup) SetNegated(negated bool) {
+	m.PathNegated = negated
+}
+
+// Options returns the options of the match, the path is quoted the same way iptables prints it
+func (m MatchCgroup) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.Path != "" {
+		options = append(options, MatchOption{Option: "path", Value: saveString(m.Path), Negated: m.PathNegated})
+	}
+	if m.ClassId != 0 {
+		options = append(options, MatchOption{Option: "cgroup", Value: strconv.FormatUint(uint64(m.ClassId), 10), Negated: m.ClassIdNegated})
+	}
+	return options
+}
+
+func (m *MatchCgroup) Parse(options []MatchOption) error {
+	for _, option := range options {
+		switch option.Option {
+		case "path":
+			m.Path = unquoteArg(option.Value)
+			m.PathNegated = option.Negated
+		case "cgroup":
+			v, err := strconv.ParseUint(option.Value, 0, 32)
+			if err != nil {
+				return fmt.Errorf("invalid value for --%s: %v", option.Option, err)
+			}
+			m.ClassId = uint32(v)
+			m.ClassIdNegated = option.Negated
+		default:
+			return fmt.Errorf("unsupported cgroup option --%s", option.Option)
+		}
+	}
+	return nil
+}
+
+func (m MatchCgroup) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchCgroup) Validate(rule Rule) error {
+	if (m.Path == "") == (m.ClassId == 0) {
+		return fmt.Errorf("cgroup match requires either a path or a class id")
+	}
+	return validateMatchChain("cgroup match", rule, ChainInput, ChainOutput, ChainPostRouting)
+}
@@ -1,14 +1,16 @@
 package iptables
 
-import "fmt"
+import (
+	"fmt"
+)
 
 const (
-	matchCommentName = "comment"
+	matchCommentName   = "comment"
 	matchCommentOption = "comment"
 )
 
 type MatchComment struct {
-	value  string
+	value string
 }
 
 func (m MatchComment) Name() string {
@@ -44,8 +46,24 @@ func (m *MatchComment) SetNegated(negated bool) {
 
 }
 
+// Options returns the comment quoted the same way iptables prints it
+func (m MatchComment) Options() []MatchOption {
+	return []MatchOption{{Option: m.Option(), Value: saveString(m.Value())}}
+}
+
+// Parse sets the comment from the options, the quotes and escapes iptables adds are removed
+func (m *MatchComment) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchCommentOption {
+			return fmt.Errorf("unsupported comment option --%s", option.Option)
+		}
+		m.value = unquoteArg(option.Value)
+	}
+	return nil
+}
+
 func (m MatchComment) String() string {
-	return fmt.Sprintf("-m %s --%s \"%s\"", m.Name(), m.Option(), m.Value())
+	return matchString(&m)
 }
 
 func (m *MatchComment) Validate(rule Rule) error {
@@ -54,4 +72,4 @@ func (m *MatchComment) Validate(rule Rule) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
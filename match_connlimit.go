@@ -0,0 +1,123 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	matchConnLimitName = "connlimit"
+)
+
+// MatchConnLimit matches on the number of connections from a source (or to a destination) address grouped by the
+// mask. Exactly one of Upto or Above must be set, they are pointers as a limit of zero is valid. Mask defaults to a
+// single address when zero
+type MatchConnLimit struct {
+	Upto            *int `json:"upto,omitempty" yaml:"upto" xml:"upto"`
+	Above           *int `json:"above,omitempty" yaml:"above" xml:"above"`
+	Mask            int  `json:"mask,omitempty" yaml:"mask" xml:"mask"`
+	DestinationAddr bool `json:"destination_addr,omitempty" yaml:"destination_addr" xml:"destination_addr"`
+}
+
+func (m MatchConnLimit) Name() string {
+	return matchConnLimitName
+}
+
+func (m *MatchConnLimit) SetName(name string) error {
+	return fmt.Errorf("connlimit match doesn't support setting the name")
+}
+
+func (m MatchConnLimit) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchConnLimit) SetOption(option string) error {
+	return fmt.Errorf("connlimit match doesn't support setting the option")
+}
+
+func (m MatchConnLimit) Value() string {
+	return firstOption(&m).Value
+}
+
+func (m *MatchConnLimit) SetValue(value string) error {
+	return fmt.Errorf("connlimit match doesn't support setting the value")
+}
+
+func (m MatchConnLimit) Negated() bool {
+	return false
+}
+
+func (m *MatchConnLimit) SetNegated(negated bool) {
+
+}
+
+func (m MatchConnLimit) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.Upto != nil {
+		options = append(options, MatchOption{Option: "connlimit-upto", Value: strconv.Itoa(*m.Upto)})
+	}
+	if m.Above != nil {
+		options = append(options, MatchOption{Option: "connlimit-above", Value: strconv.Itoa(*m.Above)})
+	}
+	if m.Mask > 0 {
+		options = append(options, MatchOption{Option: "connlimit-mask", Value: strconv.Itoa(m.Mask)})
+	}
+	if m.DestinationAddr {
+		options = append(options, MatchOption{Option: "connlimit-daddr"})
+	} else {
+		options = append(options, MatchOption{Option: "connlimit-saddr"})
+	}
+	return options
+}
+
+func (m *MatchConnLimit) Parse(options []MatchOption) (err error) {
+	for _, option := range options {
+		switch option.Option {
+		case "connlimit-upto":
+			m.Upto, err = parseConnLimit(option.Value)
+		case "connlimit-above":
+			m.Above, err = parseConnLimit(option.Value)
+		case "connlimit-mask":
+			m.Mask, err = strconv.Atoi(option.Value)
+		case "connlimit-saddr":
+			m.DestinationAddr = false
+		case "connlimit-daddr":
+			m.DestinationAddr = true
+		default:
+			return fmt.Errorf("unsupported connlimit option --%s", option.Option)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for --%s: %v", option.Option, err)
+		}
+	}
+	return nil
+}
+
+func (m MatchConnLimit) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchConnLimit) Validate(rule Rule) error {
+	if (m.Upto == nil) == (m.Above == nil) {
+		return fmt.Errorf("connlimit match requires exactly one of upto or above")
+	}
+	if (m.Upto != nil && *m.Upto < 0) || (m.Above != nil && *m.Above < 0) {
+		return fmt.Errorf("connlimit match limits can't be negative")
+	}
+	maxMask := 32
+	if rule.IpVersion == IPv6 {
+		maxMask = 128
+	}
+	if m.Mask < 0 || m.Mask > maxMask {
+		return fmt.Errorf("connlimit match mask must be between 0 and %d", maxMask)
+	}
+	return nil
+}
+
+func parseConnLimit(value string) (*int, error) {
+	limit, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
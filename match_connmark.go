@@ -0,0 +1,84 @@
+package iptables
+
+import (
+	"fmt"
+)
+
+const (
+	matchConnMarkName = "connmark"
+)
+
+// NewMatchConnMark returns a connmark match for the mark, a mask of zero compares the whole mark
+func NewMatchConnMark(mark int, mask int) *MatchConnMark {
+	return &MatchConnMark{
+		Mark: mark,
+		Mask: mask,
+	}
+}
+
+// MatchConnMark matches on the mark of the connection the packet belongs to. When Mask is set the connection mark
+// is ANDed with the mask before it is compared
+type MatchConnMark struct {
+	Mark        int  `json:"mark" yaml:"mark" xml:"mark"`
+	Mask        int  `json:"mask,omitempty" yaml:"mask" xml:"mask"`
+	MarkNegated bool `json:"mark_negated,omitempty" yaml:"mark_negated" xml:"mark_negated"`
+}
+
+func (m MatchConnMark) Name() string {
+	return matchConnMarkName
+}
+
+func (m *MatchConnMark) SetName(name string) error {
+	return fmt.Errorf("connmark match doesn't support setting the name")
+}
+
+func (m MatchConnMark) Option() string {
+	return matchMarkOption
+}
+
+func (m *MatchConnMark) SetOption(option string) error {
+	return fmt.Errorf("connmark match doesn't support setting the option")
+}
+
+func (m MatchConnMark) Value() string {
+	return formatMark(m.Mark, m.Mask)
+}
+
+// SetValue sets the mark and mask from the <mark>[/<mask>] format
+func (m *MatchConnMark) SetValue(value string) (err error) {
+	m.Mark, m.Mask, err = parseMark(value)
+	return err
+}
+
+func (m MatchConnMark) Negated() bool {
+	return m.MarkNegated
+}
+
+func (m *MatchConnMark) SetNegated(negated bool) {
+	m.MarkNegated = negated
+}
+
+func (m MatchConnMark) Options() []MatchOption {
+	return []MatchOption{{Option: matchMarkOption, Value: m.Value(), Negated: m.MarkNegated}}
+}
+
+func (m *MatchConnMark) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchMarkOption {
+			return fmt.Errorf("unsupported connmark option --%s", option.Option)
+		}
+		if err := m.SetValue(option.Value); err != nil {
+			return err
+		}
+		m.MarkNegated = option.Negated
+	}
+	return nil
+}
+
+func (m MatchConnMark) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchConnMark) Validate(rule Rule) error {
+	return validateMark("connmark match", m.Mark, m.Mask)
+}
@@ -0,0 +1,265 @@
+package iptables
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	matchConntrackName = "conntrack"
+	matchStateName     = "state"
+)
+
+// ConnTrackState represents the connection tracking states
+type ConnTrackState string
+
+const (
+	ConnTrackStateInvalid     ConnTrackState = "INVALID"
+	ConnTrackStateNew         ConnTrackState = "NEW"
+	ConnTrackStateEstablished ConnTrackState = "ESTABLISHED"
+	ConnTrackStateRelated     ConnTrackState = "RELATED"
+	ConnTrackStateUntracked   ConnTrackState = "UNTRACKED"
+	ConnTrackStateSNat        ConnTrackState = "SNAT"
+	ConnTrackStateDNat        ConnTrackState = "DNAT"
+)
+
+// ConnTrackStatus represents the connection tracking statuses
+type ConnTrackStatus string
+
+const (
+	ConnTrackStatusNone      ConnTrackStatus = "NONE"
+	ConnTrackStatusExpected  ConnTrackStatus = "EXPECTED"
+	ConnTrackStatusSeenReply ConnTrackStatus = "SEEN_REPLY"
+	ConnTrackStatusAssured   ConnTrackStatus = "ASSURED"
+	ConnTrackStatusConfirmed ConnTrackStatus = "CONFIRMED"
+)
+
+// ConnTrackDir represents the direction of a packet within a tracked connection
+type ConnTrackDir string
+
+const (
+	ConnTrackDirOriginal ConnTrackDir = "ORIGINAL"
+	ConnTrackDirReply    ConnTrackDir = "REPLY"
+)
+
+var (
+	connTrackStates   = []ConnTrackState{ConnTrackStateInvalid, ConnTrackStateNew, ConnTrackStateEstablished, ConnTrackStateRelated, ConnTrackStateUntracked, ConnTrackStateSNat, ConnTrackStateDNat}
+	stateStates       = []ConnTrackState{ConnTrackStateInvalid, ConnTrackStateNew, ConnTrackStateEstablished, ConnTrackStateRelated, ConnTrackStateUntracked}
+	connTrackStatuses = []ConnTrackStatus{ConnTrackStatusNone, ConnTrackStatusExpected, ConnTrackStatusSeenReply, ConnTrackStatusAssured, ConnTrackStatusConfirmed}
+)
+
+// NewMatchConntrack returns a conntrack match for the passed in states
+func NewMatchConntrack(states ...ConnTrackState) *MatchConntrack {
+	return &MatchConntrack{
+		States: states,
+	}
+}
+
+// MatchConntrack matches on the connection tracking state of a packet. When UseStateModule is set the match is
+// written using the legacy state module which only supports the states
+type MatchConntrack struct {
+	States                 []ConnTrackState  `json:"states,omitempty" yaml:"states" xml:"states"`
+	StatesNegated          bool              `json:"states_negated,omitempty" yaml:"states_negated" xml:"states_negated"`
+	Statuses               []ConnTrackStatus `json:"statuses,omitempty" yaml:"statuses" xml:"statuses"`
+	StatusesNegated        bool              `json:"statuses_negated,omitempty" yaml:"statuses_negated" xml:"statuses_negated"`
+	Protocol               string            `json:"protocol,omitempty" yaml:"protocol" xml:"protocol"`
+	ProtocolNegated        bool              `json:"protocol_negated,omitempty" yaml:"protocol_negated" xml:"protocol_negated"`
+	OrigSource             string            `json:"orig_source,omitempty" yaml:"orig_source" xml:"orig_source"`
+	OrigSourceNegated      bool              `json:"orig_source_negated,omitempty" yaml:"orig_source_negated" xml:"orig_source_negated"`
+	OrigDestination        string            `json:"orig_destination,omitempty" yaml:"orig_destination" xml:"orig_destination"`
+	OrigDestinationNegated bool              `json:"orig_destination_negated,omitempty" yaml:"orig_destination_negated" xml:"orig_destination_negated"`
+	Direction              ConnTrackDir      `json:"direction,omitempty" yaml:"direction" xml:"direction"`
+	Expire                 string            `json:"expire,omitempty" yaml:"expire" xml:"expire"`
+	ExpireNegated          bool              `json:"expire_negated,omitempty" yaml:"expire_negated" xml:"expire_negated"`
+	UseStateModule         bool              `json:"use_state_module,omitempty" yaml:"use_state_module" xml:"use_state_module"`
+}
+
+func (m MatchConntrack) Name() string {
+	if m.UseStateModule {
+		return matchStateName
+	}
+	return matchConntrackName
+}
+
+func (m *MatchConntrack) SetName(name string) error {
+	return fmt.Errorf("conntrack match doesn't support setting the name")
+}
+
+// Option returns the first option that is set on the match
+func (m MatchConntrack) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchConntrack) SetOption(option string) error {
+	return fmt.Errorf("conntrack match doesn't support setting the option")
+}
+
+// Value returns the value of the first option that is set on the match
+func (m MatchConntrack) Value() string {
+	return firstOption(&m).Value
+}
+
+// SetValue sets the states from a comma separated list
+func (m *MatchConntrack) SetValue(value string) error {
+	m.States = make([]ConnTrackState, 0)
+	for _, state := range strings.Split(value, ",") {
+		m.States = append(m.States, ConnTrackState(strings.ToUpper(strings.TrimSpace(state))))
+	}
+	return nil
+}
+
+// Negated returns if the states are negated
+func (m MatchConntrack) Negated() bool {
+	return m.StatesNegated
+}
+
+// SetNegated sets if the states are negated
+func (m *MatchConntrack) SetNegated(negated bool) {
+	m.StatesNegated = negated
+}
+
+// Options returns the options of the match in the order iptables prints them
+func (m MatchConntrack) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if len(m.States) > 0 {
+		states := make([]string, 0)
+		for _, state := range m.States {
+			states = append(states, string(state))
+		}
+		option := "ctstate"
+		if m.UseStateModule {
+			option = "state"
+		}
+		options = append(options, MatchOption{Option: option, Value: strings.Join(states, ","), Negated: m.StatesNegated})
+	}
+	if m.Protocol != "" {
+		options = append(options, MatchOption{Option: "ctproto", Value: m.Protocol, Negated: m.ProtocolNegated})
+	}
+	if m.OrigSource != "" {
+		options = append(options, MatchOption{Option: "ctorigsrc", Value: m.OrigSource, Negated: m.OrigSourceNegated})
+	}
+	if m.OrigDestination != "" {
+		options = append(options, MatchOption{Option: "ctorigdst", Value: m.OrigDestination, Negated: m.OrigDestinationNegated})
+	}
+	if len(m.Statuses) > 0 {
+		statuses := make([]string, 0)
+		for _, status := range m.Statuses {
+			statuses = append(statuses, string(status))
+		}
+		options = append(options, MatchOption{Option: "ctstatus", Value: strings.Join(statuses, ","), Negated: m.StatusesNegated})
+	}
+	if m.Expire != "" {
+		options = append(options, MatchOption{Option: "ctexpire", Value: m.Expire, Negated: m.ExpireNegated})
+	}
+	if m.Direction != "" {
+		options = append(options, MatchOption{Option: "ctdir", Value: string(m.Direction)})
+	}
+	return options
+}
+
+func (m MatchConntrack) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchConntrack) Validate(rule Rule) error {
+	if len(m.Options()) == 0 {
+		return fmt.Errorf("conntrack match requires at least one option")
+	}
+
+	valid := connTrackStates
+	if m.UseStateModule {
+		valid = stateStates
+		if len(m.Statuses) > 0 || m.Protocol != "" || m.OrigSource != "" || m.OrigDestination != "" || m.Direction != "" || m.Expire != "" {
+			return fmt.Errorf("state match only supports the states, use the conntrack match for other options")
+		}
+	}
+	for _, state := range m.States {
+		if !containsConnTrackState(valid, state) {
+			return fmt.Errorf("invalid %s state %s", m.Name(), state)
+		}
+	}
+
+	for _, status := range m.Statuses {
+		found := false
+		for _, s := range connTrackStatuses {
+			if s == status {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid conntrack status %s", status)
+		}
+	}
+
+	if m.Direction != "" && m.Direction != ConnTrackDirOriginal && m.Direction != ConnTrackDirReply {
+		return fmt.Errorf("invalid conntrack direction %s", m.Direction)
+	}
+
+	for _, address := range []string{m.OrigSource, m.OrigDestination} {
+		if address == "" {
+			continue
+		}
+		if net.ParseIP(address) == nil {
+			if _, _, err := net.ParseCIDR(address); err != nil {
+				return fmt.Errorf("invalid conntrack address %s", address)
+			}
+		}
+	}
+
+	if m.Expire != "" {
+		for _, part := range strings.Split(m.Expire, ":") {
+			if _, err := strconv.ParseUint(part, 10, 32); err != nil {
+				return fmt.Errorf("invalid conntrack expire %s. expected <seconds>[:<seconds>]", m.Expire)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Parse sets the match from the options read from a rule using either the conntrack or state module
+func (m *MatchConntrack) Parse(options []MatchOption) error {
+	for _, option := range options {
+		switch option.Option {
+		case "ctstate", "state":
+			if err := m.SetValue(option.Value); err != nil {
+				return err
+			}
+			m.StatesNegated = option.Negated
+		case "ctstatus":
+			m.Statuses = make([]ConnTrackStatus, 0)
+			for _, status := range strings.Split(option.Value, ",") {
+				m.Statuses = append(m.Statuses, ConnTrackStatus(status))
+			}
+			m.StatusesNegated = option.Negated
+		case "ctproto":
+			m.Protocol = option.Value
+			m.ProtocolNegated = option.Negated
+		case "ctorigsrc":
+			m.OrigSource = option.Value
+			m.OrigSourceNegated = option.Negated
+		case "ctorigdst":
+			m.OrigDestination = option.Value
+			m.OrigDestinationNegated = option.Negated
+		case "ctexpire":
+			m.Expire = option.Value
+			m.ExpireNegated = option.Negated
+		case "ctdir":
+			m.Direction = ConnTrackDir(option.Value)
+		default:
+			return fmt.Errorf("unsupported %s option --%s", m.Name(), option.Option)
+		}
+	}
+	return nil
+}
+
+func containsConnTrackState(states []ConnTrackState, state ConnTrackState) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
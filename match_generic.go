@@ -2,8 +2,6 @@ package iptables
 
 import "fmt"
 
-const ()
-
 func NewMatchGeneric(name string, option string, value string, negated bool) *MatchGeneric {
 	m := &MatchGeneric{}
 	m.SetName(name)
@@ -13,11 +11,24 @@ func NewMatchGeneric(name string, option string, value string, negated bool) *Ma
 	return m
 }
 
+// NewMatchGenericWithOptions returns a generic match for the module with all of the passed in options. The values
+// are used as they are read from a rule, already quoted where needed and with multiple arguments separated by spaces
+func NewMatchGenericWithOptions(name string, options ...MatchOption) *MatchGeneric {
+	m := &MatchGeneric{}
+	m.SetName(name)
+	for _, option := range options {
+		m.options = append(m.options, option)
+		m.raw = append(m.raw, true)
+	}
+	return m
+}
+
+// MatchGeneric is a match for any module. Values set with SetValue or AddOption are a single argument and are quoted
+// when needed, values read from a rule are kept as the arguments that were read
 type MatchGeneric struct {
 	name    string
-	option  string
-	value   string
-	negated bool
+	options []MatchOption
+	raw     []bool
 }
 
 func (m MatchGeneric) Name() string {
@@ -30,36 +41,96 @@ func (m *MatchGeneric) SetName(name string) error {
 }
 
 func (m MatchGeneric) Option() string {
-	return m.option
+	return firstOption(&m).Option
 }
 
 func (m *MatchGeneric) SetOption(option string) error {
-	m.option = option
+	m.first().Option = option
 	return nil
 }
 
 func (m MatchGeneric) Value() string {
-	return m.value
+	if len(m.options) == 0 {
+		return ""
+	}
+	return m.value(0)
 }
 
 func (m *MatchGeneric) SetValue(value string) error {
-	m.value = value
+	m.first().Value = value
+	m.raw[0] = false
 	return nil
 }
 
 func (m MatchGeneric) Negated() bool {
-	return m.negated
+	return firstOption(&m).Negated
 }
 
 func (m *MatchGeneric) SetNegated(negated bool) {
-	m.negated = negated
+	m.first().Negated = negated
+}
+
+// AddOption appends an option to the match
+func (m *MatchGeneric) AddOption(option string, value string, negated bool) {
+	m.options = append(m.options, MatchOption{
+		Option:  option,
+		Value:   value,
+		Negated: negated,
+	})
+	m.raw = append(m.raw, false)
+}
+
+// Options returns the options with their values in argument form
+func (m MatchGeneric) Options() []MatchOption {
+	options := make([]MatchOption, len(m.options))
+	copy(options, m.options)
+	for idx := range options {
+		if !m.raw[idx] && options[idx].Value != "" {
+			options[idx].Value = quoteArg(options[idx].Value)
+		}
+	}
+	return options
+}
+
+func (m *MatchGeneric) Parse(options []MatchOption) error {
+	m.options = append(make([]MatchOption, 0), options...)
+	m.raw = make([]bool, len(options))
+	for idx := range m.raw {
+		m.raw[idx] = true
+	}
+	return nil
+}
+
+// value returns the value of the option without quotes. Values read from a rule are only unquoted when they are a
+// single argument
+func (m MatchGeneric) value(idx int) string {
+	value := m.options[idx].Value
+	if !m.raw[idx] {
+		return value
+	}
+	args, err := splitArgs(value)
+	if err != nil || len(args) != 1 {
+		return value
+	}
+	return unquoteArg(args[0])
+}
+
+// first returns the first option creating it if needed
+func (m *MatchGeneric) first() *MatchOption {
+	if len(m.options) == 0 {
+		m.options = append(m.options, MatchOption{})
+		m.raw = append(m.raw, false)
+	}
+	return &m.options[0]
 }
 
 func (m MatchGeneric) String() string {
-	return fmt.Sprintf("-m %s --%s \"%s\"", m.Name(), m.Option(), m.Value())
+	return matchString(&m)
 }
 
 func (m *MatchGeneric) Validate(rule Rule) error {
-	// Generic doesn't have any validation
+	if m.name == "" {
+		return fmt.Errorf("generic match requires a name")
+	}
 	return nil
 }
@@ -0,0 +1,204 @@
+package iptables
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	matchHashLimitName = "hashlimit"
+)
+
+var (
+	hashLimitBurstRegex = regexp.MustCompile(`^[0-9]+(b|kb|mb)?$`)
+)
+
+// HashLimitMode selects which fields of the packet are used to group packets into separate rate limits
+type HashLimitMode string
+
+const (
+	HashLimitModeSourceIp        HashLimitMode = "srcip"
+	HashLimitModeSourcePort      HashLimitMode = "srcport"
+	HashLimitModeDestinationIp   HashLimitMode = "dstip"
+	HashLimitModeDestinationPort HashLimitMode = "dstport"
+)
+
+// MatchHashLimit applies a separate rate limit to every group of packets selected by the modes, for example a
+// limit per source address. Exactly one of Upto or Above must be set. Burst is a number of packets, or of bytes with
+// a b, kb or mb suffix when the rate is in bytes. The htable settings are optional and HtableExpire and
+// HtableGCInterval are in milliseconds
+type MatchHashLimit struct {
+	HashName         string          `json:"hash_name" yaml:"hash_name" xml:"hash_name"`
+	Upto             string          `json:"upto,omitempty" yaml:"upto" xml:"upto"`
+	Above            string          `json:"above,omitempty" yaml:"above" xml:"above"`
+	Burst            string          `json:"burst,omitempty" yaml:"burst" xml:"burst"`
+	Modes            []HashLimitMode `json:"modes,omitempty" yaml:"modes" xml:"modes"`
+	SourceMask       int             `json:"source_mask,omitempty" yaml:"source_mask" xml:"source_mask"`
+	DestinationMask  int             `json:"destination_mask,omitempty" yaml:"destination_mask" xml:"destination_mask"`
+	HtableSize       int             `json:"htable_size,omitempty" yaml:"htable_size" xml:"htable_size"`
+	HtableMax        int             `json:"htable_max,omitempty" yaml:"htable_max" xml:"htable_max"`
+	HtableExpire     int             `json:"htable_expire,omitempty" yaml:"htable_expire" xml:"htable_expire"`
+	HtableGCInterval int             `json:"htable_gc_interval,omitempty" yaml:"htable_gc_interval" xml:"htable_gc_interval"`
+}
+
+func (m MatchHashLimit) Name() string {
+	return matchHashLimitName
+}
+
+func (m *MatchHashLimit) SetName(name string) error {
+	return fmt.Errorf("hashlimit match doesn't support setting the name")
+}
+
+func (m MatchHashLimit) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchHashLimit) SetOption(option string) error {
+	return fmt.Errorf("hashlimit match doesn't support setting the option")
+}
+
+func (m MatchHashLimit) Value() string {
+	return firstOption(&m).Value
+}
+
+func (m *MatchHashLimit) SetValue(value string) error {
+	return fmt.Errorf("hashlimit match doesn't support setting the value")
+}
+
+func (m MatchHashLimit) Negated() bool {
+	return false
+}
+
+func (m *MatchHashLimit) SetNegated(negated bool) {
+
+}
+
+func (m MatchHashLimit) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.Upto != "" {
+		options = append(options, MatchOption{Option: "hashlimit-upto", Value: m.Upto})
+	}
+	if m.Above != "" {
+		options = append(options, MatchOption{Option: "hashlimit-above", Value: m.Above})
+	}
+	if m.Burst != "" {
+		options = append(options, MatchOption{Option: "hashlimit-burst", Value: m.Burst})
+	}
+	if len(m.Modes) > 0 {
+		modes := make([]string, 0)
+		for _, mode := range m.Modes {
+			modes = append(modes, string(mode))
+		}
+		options = append(options, MatchOption{Option: "hashlimit-mode", Value: strings.Join(modes, ",")})
+	}
+	if m.SourceMask > 0 {
+		options = append(options, MatchOption{Option: "hashlimit-srcmask", Value: strconv.Itoa(m.SourceMask)})
+	}
+	if m.DestinationMask > 0 {
+		options = append(options, MatchOption{Option: "hashlimit-dstmask", Value: strconv.Itoa(m.DestinationMask)})
+	}
+	options = append(options, MatchOption{Option: "hashlimit-name", Value: m.HashName})
+	if m.HtableSize > 0 {
+		options = append(options, MatchOption{Option: "hashlimit-htable-size", Value: strconv.Itoa(m.HtableSize)})
+	}
+	if m.HtableMax > 0 {
+		options = append(options, MatchOption{Option: "hashlimit-htable-max", Value: strconv.Itoa(m.HtableMax)})
+	}
+	if m.HtableExpire > 0 {
+		options = append(options, MatchOption{Option: "hashlimit-htable-expire", Value: strconv.Itoa(m.HtableExpire)})
+	}
+	if m.HtableGCInterval > 0 {
+		options = append(options, MatchOption{Option: "hashlimit-htable-gcinterval", Value: strconv.Itoa(m.HtableGCInterval)})
+	}
+	return options
+}
+
+func (m *MatchHashLimit) Parse(options []MatchOption) (err error) {
+	for _, option := range options {
+		switch option.Option {
+		case "hashlimit-upto", "hashlimit":
+			m.Upto = option.Value
+		case "hashlimit-above":
+			m.Above = option.Value
+		case "hashlimit-burst":
+			m.Burst = option.Value
+		case "hashlimit-mode":
+			m.Modes = make([]HashLimitMode, 0)
+			for _, mode := range strings.Split(option.Value, ",") {
+				m.Modes = append(m.Modes, HashLimitMode(mode))
+			}
+		case "hashlimit-srcmask":
+			m.SourceMask, err = strconv.Atoi(option.Value)
+		case "hashlimit-dstmask":
+			m.DestinationMask, err = strconv.Atoi(option.Value)
+		case "hashlimit-name":
+			m.HashName = option.Value
+		case "hashlimit-htable-size":
+			m.HtableSize, err = strconv.Atoi(option.Value)
+		case "hashlimit-htable-max":
+			m.HtableMax, err = strconv.Atoi(option.Value)
+		case "hashlimit-htable-expire":
+			m.HtableExpire, err = strconv.Atoi(option.Value)
+		case "hashlimit-htable-gcinterval":
+			m.HtableGCInterval, err = strconv.Atoi(option.Value)
+		default:
+			return fmt.Errorf("unsupported hashlimit option --%s", option.Option)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for --%s: %v", option.Option, err)
+		}
+	}
+	return nil
+}
+
+func (m MatchHashLimit) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchHashLimit) Validate(rule Rule) error {
+	if m.HashName == "" {
+		return fmt.Errorf("hashlimit match requires a name")
+	}
+	if (m.Upto == "") == (m.Above == "") {
+		return fmt.Errorf("hashlimit match requires exactly one of upto or above")
+	}
+	for _, rate := range []string{m.Upto, m.Above} {
+		if rate == "" {
+			continue
+		}
+		if err := validateRate(rate, true); err != nil {
+			return fmt.Errorf("hashlimit match %v", err)
+		}
+	}
+	if m.Burst != "" && !hashLimitBurstRegex.MatchString(strings.ToLower(m.Burst)) {
+		return fmt.Errorf("invalid hashlimit match burst %s. expected <count> or <count><b|kb|mb>", m.Burst)
+	}
+	if m.Burst != "" && strings.ContainsAny(strings.ToLower(m.Burst), "bkm") {
+		for _, rate := range []string{m.Upto, m.Above} {
+			if rate != "" && !byteRateRegex.MatchString(strings.ToLower(rate)) {
+				return fmt.Errorf("hashlimit match burst %s with a unit requires a rate in bytes", m.Burst)
+			}
+		}
+	}
+	for _, mode := range m.Modes {
+		switch mode {
+		case HashLimitModeSourceIp, HashLimitModeSourcePort, HashLimitModeDestinationIp, HashLimitModeDestinationPort:
+		default:
+			return fmt.Errorf("invalid hashlimit mode %s", mode)
+		}
+	}
+
+	maxMask := 32
+	if rule.IpVersion == IPv6 {
+		maxMask = 128
+	}
+	if m.SourceMask < 0 || m.SourceMask > maxMask || m.DestinationMask < 0 || m.DestinationMask > maxMask {
+		return fmt.Errorf("hashlimit match masks must be between 0 and %d", maxMask)
+	}
+	if m.HtableSize < 0 || m.HtableMax < 0 || m.HtableExpire < 0 || m.HtableGCInterval < 0 {
+		return fmt.Errorf("hashlimit match htable settings can't be negative")
+	}
+	return nil
+}
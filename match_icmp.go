@@ -0,0 +1,284 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	matchICMPName     = "icmp"
+	matchICMPOption   = "icmp-type"
+	matchICMPv6Name   = "icmp6"
+	matchICMPv6Option = "icmpv6-type"
+)
+
+var (
+	// icmpTypes maps the ICMP type names iptables accepts to their type and optional code
+	icmpTypes = map[string]string{
+		"any":                        "any",
+		"echo-reply":                 "0",
+		"pong":                       "0",
+		"destination-unreachable":    "3",
+		"network-unreachable":        "3/0",
+		"host-unreachable":           "3/1",
+		"protocol-unreachable":       "3/2",
+		"port-unreachable":           "3/3",
+		"fragmentation-needed":       "3/4",
+		"source-route-failed":        "3/5",
+		"network-unknown":            "3/6",
+		"host-unknown":               "3/7",
+		"network-prohibited":         "3/9",
+		"host-prohibited":            "3/10",
+		"TOS-network-unreachable":    "3/11",
+		"TOS-host-unreachable":       "3/12",
+		"communication-prohibited":   "3/13",
+		"host-precedence-violation":  "3/14",
+		"precedence-cutoff":          "3/15",
+		"source-quench":              "4",
+		"redirect":                   "5",
+		"network-redirect":           "5/0",
+		"host-redirect":              "5/1",
+		"TOS-network-redirect":       "5/2",
+		"TOS-host-redirect":          "5/3",
+		"echo-request":               "8",
+		"ping":                       "8",
+		"router-advertisement":       "9",
+		"router-solicitation":        "10",
+		"time-exceeded":              "11",
+		"ttl-exceeded":               "11",
+		"ttl-zero-during-transit":    "11/0",
+		"ttl-zero-during-reassembly": "11/1",
+		"parameter-problem":          "12",
+		"ip-header-bad":              "12/0",
+		"required-option-missing":    "12/1",
+		"timestamp-request":          "13",
+		"timestamp-reply":            "14",
+		"address-mask-request":       "17",
+		"address-mask-reply":         "18",
+	}
+
+	// icmpv6Types maps the ICMPv6 type names ip6tables accepts to their type and optional code
+	icmpv6Types = map[string]string{
+		"destination-unreachable":    "1",
+		"no-route":                   "1/0",
+		"communication-prohibited":   "1/1",
+		"beyond-scope":               "1/2",
+		"address-unreachable":        "1/3",
+		"port-unreachable":           "1/4",
+		"failed-policy":              "1/5",
+		"reject-route":               "1/6",
+		"packet-too-big":             "2",
+		"time-exceeded":              "3",
+		"ttl-exceeded":               "3",
+		"ttl-zero-during-transit":    "3/0",
+		"ttl-zero-during-reassembly": "3/1",
+		"parameter-problem":          "4",
+		"bad-header":                 "4/0",
+		"unknown-header-type":        "4/1",
+		"unknown-option":             "4/2",
+		"echo-request":               "128",
+		"ping":                       "128",
+		"echo-reply":                 "129",
+		"pong":                       "129",
+		"mld-listener-query":         "130",
+		"mld-listener-report":        "131",
+		"mld-listener-done":          "132",
+		"mld-listener-reduction":     "132",
+		"router-solicitation":        "133",
+		"router-advertisement":       "134",
+		"neighbour-solicitation":     "135",
+		"neighbor-solicitation":      "135",
+		"neighbour-advertisement":    "136",
+		"neighbor-advertisement":     "136",
+		"redirect":                   "137",
+	}
+)
+
+// NewMatchICMP returns an icmp match for the ICMP type name or <type>[/<code>]
+func NewMatchICMP(icmpType string) *MatchICMP {
+	return &MatchICMP{
+		Type: icmpType,
+	}
+}
+
+// MatchICMP matches the type and code of ICMP packets in IPv4 rules. Type is either one of the names iptables
+// accepts, such as echo-request, or <type>[/<code>]. Names are written as numbers which is how iptables prints them.
+// The rule protocol must be icmp
+type MatchICMP struct {
+	Type        string `json:"type" yaml:"type" xml:"type"`
+	TypeNegated bool   `json:"type_negated,omitempty" yaml:"type_negated" xml:"type_negated"`
+}
+
+func (m MatchICMP) Name() string {
+	return matchICMPName
+}
+
+func (m *MatchICMP) SetName(name string) error {
+	return fmt.Errorf("icmp match doesn't support setting the name")
+}
+
+func (m MatchICMP) Option() string {
+	return matchICMPOption
+}
+
+func (m *MatchICMP) SetOption(option string) error {
+	return fmt.Errorf("icmp match doesn't support setting the option")
+}
+
+// Value returns the type as a number or <type>/<code>
+func (m MatchICMP) Value() string {
+	if resolved, err := resolveICMPType(icmpTypes, m.Type); err == nil {
+		return resolved
+	}
+	return m.Type
+}
+
+func (m *MatchICMP) SetValue(value string) error {
+	m.Type = value
+	return nil
+}
+
+func (m MatchICMP) Negated() bool {
+	return m.TypeNegated
+}
+
+func (m *MatchICMP) SetNegated(negated bool) {
+	m.TypeNegated = negated
+}
+
+func (m MatchICMP) Options() []MatchOption {
+	return []MatchOption{{Option: matchICMPOption, Value: m.Value(), Negated: m.TypeNegated}}
+}
+
+func (m *MatchICMP) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchICMPOption {
+			return fmt.Errorf("unsupported icmp option --%s", option.Option)
+		}
+		m.Type = option.Value
+		m.TypeNegated = option.Negated
+	}
+	return nil
+}
+
+func (m MatchICMP) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchICMP) Validate(rule Rule) error {
+	if rule.IpVersion == IPv6 {
+		return fmt.Errorf("icmp match can't be used in an %s rule, use the icmp6 match", IPv6)
+	}
+	if rule.Protocol != ProtocolICMP || rule.ProtocolNegated {
+		return fmt.Errorf("icmp match requires the protocol to be %s", ProtocolICMP)
+	}
+	if _, err := resolveICMPType(icmpTypes, m.Type); err != nil {
+		return fmt.Errorf("icmp match %v", err)
+	}
+	return nil
+}
+
+// NewMatchICMPv6 returns an icmp6 match for the ICMPv6 type name or <type>[/<code>]
+func NewMatchICMPv6(icmpType string) *MatchICMPv6 {
+	return &MatchICMPv6{
+		Type: icmpType,
+	}
+}
+
+// MatchICMPv6 matches the type and code of ICMPv6 packets in IPv6 rules. Type is either one of the names ip6tables
+// accepts, such as echo-request, or <type>[/<code>]. Names are written as numbers which is how ip6tables prints
+// them. The rule protocol must be ipv6-icmp
+type MatchICMPv6 struct {
+	Type        string `json:"type" yaml:"type" xml:"type"`
+	TypeNegated bool   `json:"type_negated,omitempty" yaml:"type_negated" xml:"type_negated"`
+}
+
+func (m MatchICMPv6) Name() string {
+	return matchICMPv6Name
+}
+
+func (m *MatchICMPv6) SetName(name string) error {
+	return fmt.Errorf("icmp6 match doesn't support setting the name")
+}
+
+func (m MatchICMPv6) Option() string {
+	return matchICMPv6Option
+}
+
+func (m *MatchICMPv6) SetOption(option string) error {
+	return fmt.Errorf("icmp6 match doesn't support setting the option")
+}
+
+// Value returns the type as a number or <type>/<code>
+func (m MatchICMPv6) Value() string {
+	if resolved, err := resolveICMPType(icmpv6Types, m.Type); err == nil {
+		return resolved
+	}
+	return m.Type
+}
+
+func (m *MatchICMPv6) SetValue(value string) error {
+	m.Type = value
+	return nil
+}
+
+func (m MatchICMPv6) Negated() bool {
+	return m.TypeNegated
+}
+
+func (m *MatchICMPv6) SetNegated(negated bool) {
+	m.TypeNegated = negated
+}
+
+func (m MatchICMPv6) Options() []MatchOption {
+	return []MatchOption{{Option: matchICMPv6Option, Value: m.Value(), Negated: m.TypeNegated}}
+}
+
+func (m *MatchICMPv6) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchICMPv6Option {
+			return fmt.Errorf("unsupported icmp6 option --%s", option.Option)
+		}
+		m.Type = option.Value
+		m.TypeNegated = option.Negated
+	}
+	return nil
+}
+
+func (m MatchICMPv6) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchICMPv6) Validate(rule Rule) error {
+	if rule.IpVersion != IPv6 {
+		return fmt.Errorf("icmp6 match can only be used in an %s rule, use the icmp match", IPv6)
+	}
+	if !IsICMPv6Protocol(rule.Protocol) || rule.ProtocolNegated {
+		return fmt.Errorf("icmp6 match requires the protocol to be %s", ProtocolICMPv6)
+	}
+	if _, err := resolveICMPType(icmpv6Types, m.Type); err != nil {
+		return fmt.Errorf("icmp6 match %v", err)
+	}
+	return nil
+}
+
+// resolveICMPType returns the type in the numeric <type>[/<code>] format from either a name in the types or a
+// numeric type with an optional code
+func resolveICMPType(types map[string]string, value string) (string, error) {
+	for name, resolved := range types {
+		if strings.EqualFold(name, value) {
+			return resolved, nil
+		}
+	}
+	parts := strings.Split(value, "/")
+	if len(parts) > 2 {
+		return "", fmt.Errorf("invalid type '%s'. expected a name or <type>[/<code>]", value)
+	}
+	for _, part := range parts {
+		if _, err := strconv.ParseUint(part, 10, 8); err != nil {
+			return "", fmt.Errorf("invalid type '%s'. expected a name or <type>[/<code>]", value)
+		}
+	}
+	return value, nil
+}
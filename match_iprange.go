@@ -0,0 +1,133 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	matchIpRangeName = "iprange"
+)
+
+// NewMatchIpRange returns an iprange match for the source range in the <first>-<last> format
+func NewMatchIpRange(srcRange string) *MatchIpRange {
+	return &MatchIpRange{
+		SrcRange: srcRange,
+	}
+}
+
+// MatchIpRange matches source and/or destination addresses within an inclusive range in the <first>-<last> format
+type MatchIpRange struct {
+	SrcRange        string `json:"src_range,omitempty" yaml:"src_range" xml:"src_range"`
+	SrcRangeNegated bool   `json:"src_range_negated,omitempty" yaml:"src_range_negated" xml:"src_range_negated"`
+	DstRange        string `json:"dst_range,omitempty" yaml:"dst_range" xml:"dst_range"`
+	DstRangeNegated bool   `json:"dst_range_negated,omitempty" yaml:"dst_range_negated" xml:"dst_range_negated"`
+}
+
+func (m MatchIpRange) Name() string {
+	return matchIpRangeName
+}
+
+func (m *MatchIpRange) SetName(name string) error {
+	return fmt.Errorf("iprange match doesn't support setting the name")
+}
+
+// Option returns the first option that is set on the match
+func (m MatchIpRange) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchIpRange) SetOption(option string) error {
+	return fmt.Errorf("iprange match doesn't support setting the option")
+}
+
+// Value returns the value of the first option that is set on the match
+func (m MatchIpRange) Value() string {
+	return firstOption(&m).Value
+}
+
+func (m *MatchIpRange) SetValue(value string) error {
+	return fmt.Errorf("iprange match doesn't support setting the value")
+}
+
+// Negated returns if the first option that is set on the match is negated
+func (m MatchIpRange) Negated() bool {
+	return firstOption(&m).Negated
+}
+
+// SetNegated does nothing as each option is negated on its own, use the negated fields instead
+func (m *MatchIpRange) SetNegated(negated bool) {
+
+}
+
+func (m MatchIpRange) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.SrcRange != "" {
+		options = append(options, MatchOption{Option: "src-range", Value: m.SrcRange, Negated: m.SrcRangeNegated})
+	}
+	if m.DstRange != "" {
+		options = append(options, MatchOption{Option: "dst-range", Value: m.DstRange, Negated: m.DstRangeNegated})
+	}
+	return options
+}
+
+func (m *MatchIpRange) Parse(options []MatchOption) error {
+	for _, option := range options {
+		switch option.Option {
+		case "src-range":
+			m.SrcRange = option.Value
+			m.SrcRangeNegated = option.Negated
+		case "dst-range":
+			m.DstRange = option.Value
+			m.DstRangeNegated = option.Negated
+		default:
+			return fmt.Errorf("unsupported iprange option --%s", option.Option)
+		}
+	}
+	return nil
+}
+
+func (m MatchIpRange) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchIpRange) Validate(rule Rule) error {
+	if m.SrcRange == "" && m.DstRange == "" {
+		return fmt.Errorf("iprange match requires a source or destination range")
+	}
+	for _, r := range []string{m.SrcRange, m.DstRange} {
+		if r == "" {
+			continue
+		}
+		parts := strings.Split(r, "-")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid iprange match range %s. expected <first>-<last>", r)
+		}
+		first, last := net.ParseIP(parts[0]), net.ParseIP(parts[1])
+		if first == nil || last == nil {
+			return fmt.Errorf("invalid iprange match range %s. expected <first>-<last>", r)
+		}
+		for _, ip := range []net.IP{first, last} {
+			if err := validateAddressFamily("iprange match", ip, rule.IpVersion); err != nil {
+				return err
+			}
+		}
+		if bytes.Compare(first.To16(), last.To16()) > 0 {
+			return fmt.Errorf("iprange match range %s ends before it starts", r)
+		}
+	}
+	return nil
+}
+
+// validateAddressFamily returns an error if the address doesn't belong to the ip version of the rule
+func validateAddressFamily(kind string, ip net.IP, ver IPVer) error {
+	if ver == "" {
+		ver = IPv4
+	}
+	if (ip.To4() != nil) != (ver == IPv4) {
+		return fmt.Errorf("%s address %s can't be used in an %s rule", kind, ip, ver)
+	}
+	return nil
+}
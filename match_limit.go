@@ -0,0 +1,119 @@
+package iptables
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	matchLimitName = "limit"
+)
+
+var (
+	rateRegex     = regexp.MustCompile(`^[0-9]+/(s|sec|second|m|min|minute|h|hour|d|day)$`)
+	byteRateRegex = regexp.MustCompile(`^[0-9]+(b|kb|mb)/s$`)
+)
+
+// NewMatchLimit returns a limit match for the rate, for example 10/second, with the burst
+func NewMatchLimit(rate string, burst int) *MatchLimit {
+	return &MatchLimit{
+		Rate:  rate,
+		Burst: burst,
+	}
+}
+
+// MatchLimit matches packets until the rate limit is reached. Burst is the number of packets that can be matched
+// before the limit applies, zero uses the iptables default
+type MatchLimit struct {
+	Rate  string `json:"rate" yaml:"rate" xml:"rate"`
+	Burst int    `json:"burst,omitempty" yaml:"burst" xml:"burst"`
+}
+
+func (m MatchLimit) Name() string {
+	return matchLimitName
+}
+
+func (m *MatchLimit) SetName(name string) error {
+	return fmt.Errorf("limit match doesn't support setting the name")
+}
+
+func (m MatchLimit) Option() string {
+	return "limit"
+}
+
+func (m *MatchLimit) SetOption(option string) error {
+	return fmt.Errorf("limit match doesn't support setting the option")
+}
+
+func (m MatchLimit) Value() string {
+	return m.Rate
+}
+
+// SetValue sets the rate
+func (m *MatchLimit) SetValue(value string) error {
+	m.Rate = value
+	return nil
+}
+
+func (m MatchLimit) Negated() bool {
+	return false
+}
+
+func (m *MatchLimit) SetNegated(negated bool) {
+
+}
+
+func (m MatchLimit) Options() []MatchOption {
+	options := []MatchOption{{Option: "limit", Value: m.Rate}}
+	if m.Burst > 0 {
+		options = append(options, MatchOption{Option: "limit-burst", Value: strconv.Itoa(m.Burst)})
+	}
+	return options
+}
+
+func (m *MatchLimit) Parse(options []MatchOption) (err error) {
+	for _, option := range options {
+		switch option.Option {
+		case "limit":
+			m.Rate = option.Value
+		case "limit-burst":
+			m.Burst, err = strconv.Atoi(option.Value)
+		default:
+			return fmt.Errorf("unsupported limit option --%s", option.Option)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for --%s: %v", option.Option, err)
+		}
+	}
+	return nil
+}
+
+func (m MatchLimit) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchLimit) Validate(rule Rule) error {
+	if err := validateRate(m.Rate, false); err != nil {
+		return fmt.Errorf("limit match %v", err)
+	}
+	if m.Burst < 0 {
+		return fmt.Errorf("limit match burst can't be negative")
+	}
+	return nil
+}
+
+// validateRate checks that the rate has the <count>/<unit> format where unit is second, minute, hour or day or one
+// of their abbreviations. When bytes is true the <count><b|kb|mb>/s format is also accepted
+func validateRate(rate string, bytes bool) error {
+	rate = strings.ToLower(rate)
+	if rateRegex.MatchString(rate) || (bytes && byteRateRegex.MatchString(rate)) {
+		count, _ := strconv.Atoi(strings.TrimRight(strings.Split(rate, "/")[0], "bkm"))
+		if count == 0 {
+			return fmt.Errorf("rate %s must be greater than zero", rate)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid rate %s. expected <count>/<second|minute|hour|day>", rate)
+}
@@ -0,0 +1,90 @@
+package iptables
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	matchMacName   = "mac"
+	matchMacOption = "mac-source"
+)
+
+// NewMatchMac returns a mac match for the source MAC address
+func NewMatchMac(macSource string) *MatchMac {
+	return &MatchMac{
+		MacSource: macSource,
+	}
+}
+
+// MatchMac matches the source MAC address of packets entering the system. It is only valid on the PREROUTING,
+// INPUT and FORWARD chains
+type MatchMac struct {
+	MacSource        string `json:"mac_source" yaml:"mac_source" xml:"mac_source"`
+	MacSourceNegated bool   `json:"mac_source_negated,omitempty" yaml:"mac_source_negated" xml:"mac_source_negated"`
+}
+
+func (m MatchMac) Name() string {
+	return matchMacName
+}
+
+func (m *MatchMac) SetName(name string) error {
+	return fmt.Errorf("mac match doesn't support setting the name")
+}
+
+func (m MatchMac) Option() string {
+	return matchMacOption
+}
+
+func (m *MatchMac) SetOption(option string) error {
+	return fmt.Errorf("mac match doesn't support setting the option")
+}
+
+// Value returns the MAC address in the upper case format iptables prints
+func (m MatchMac) Value() string {
+	if hw, err := net.ParseMAC(m.MacSource); err == nil {
+		return strings.ToUpper(hw.String())
+	}
+	return m.MacSource
+}
+
+func (m *MatchMac) SetValue(value string) error {
+	m.MacSource = value
+	return nil
+}
+
+func (m MatchMac) Negated() bool {
+	return m.MacSourceNegated
+}
+
+func (m *MatchMac) SetNegated(negated bool) {
+	m.MacSourceNegated = negated
+}
+
+func (m MatchMac) Options() []MatchOption {
+	return []MatchOption{{Option: matchMacOption, Value: m.Value(), Negated: m.MacSourceNegated}}
+}
+
+func (m *MatchMac) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchMacOption {
+			return fmt.Errorf("unsupported mac option --%s", option.Option)
+		}
+		m.MacSource = option.Value
+		m.MacSourceNegated = option.Negated
+	}
+	return nil
+}
+
+func (m MatchMac) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchMac) Validate(rule Rule) error {
+	hw, err := net.ParseMAC(m.MacSource)
+	if err != nil || len(hw) != 6 {
+		return fmt.Errorf("invalid mac match address '%s'. expected XX:XX:XX:XX:XX:XX", m.MacSource)
+	}
+	return validateMatchChain("mac match", rule, ChainPreRouting, ChainInput, ChainForward)
+}
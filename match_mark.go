@@ -0,0 +1,140 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	matchMarkName   = "mark"
+	matchMarkOption = "mark"
+
+	// markMaskAll is the mask iptables uses when no mask is given
+	markMaskAll = 0xffffffff
+)
+
+// NewMatchMark returns a mark match for the mark, a mask of zero compares the whole mark
+func NewMatchMark(mark int, mask int) *MatchMark {
+	return &MatchMark{
+		Mark: mark,
+		Mask: mask,
+	}
+}
+
+// MatchMark matches on the netfilter mark of the packet. When Mask is set the mark of the packet is ANDed with the
+// mask before it is compared
+type MatchMark struct {
+	Mark        int  `json:"mark" yaml:"mark" xml:"mark"`
+	Mask        int  `json:"mask,omitempty" yaml:"mask" xml:"mask"`
+	MarkNegated bool `json:"mark_negated,omitempty" yaml:"mark_negated" xml:"mark_negated"`
+}
+
+func (m MatchMark) Name() string {
+	return matchMarkName
+}
+
+func (m *MatchMark) SetName(name string) error {
+	return fmt.Errorf("mark match doesn't support setting the name")
+}
+
+func (m MatchMark) Option() string {
+	return matchMarkOption
+}
+
+func (m *MatchMark) SetOption(option string) error {
+	return fmt.Errorf("mark match doesn't support setting the option")
+}
+
+func (m MatchMark) Value() string {
+	return formatMark(m.Mark, m.Mask)
+}
+
+// SetValue sets the mark and mask from the <mark>[/<mask>] format
+func (m *MatchMark) SetValue(value string) (err error) {
+	m.Mark, m.Mask, err = parseMark(value)
+	return err
+}
+
+func (m MatchMark) Negated() bool {
+	return m.MarkNegated
+}
+
+func (m *MatchMark) SetNegated(negated bool) {
+	m.MarkNegated = negated
+}
+
+func (m MatchMark) Options() []MatchOption {
+	return []MatchOption{{Option: matchMarkOption, Value: m.Value(), Negated: m.MarkNegated}}
+}
+
+func (m *MatchMark) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchMarkOption {
+			return fmt.Errorf("unsupported mark option --%s", option.Option)
+		}
+		if err := m.SetValue(option.Value); err != nil {
+			return err
+		}
+		m.MarkNegated = option.Negated
+	}
+	return nil
+}
+
+func (m MatchMark) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchMark) Validate(rule Rule) error {
+	return validateMark("mark match", m.Mark, m.Mask)
+}
+
+// formatMark returns the mark in the <mark>[/<mask>] format printed by iptables, the mask is left out when it
+// covers the whole mark
+func formatMark(mark int, mask int) string {
+	if mask == 0 || mask == markMaskAll {
+		return fmt.Sprintf("0x%x", mark)
+	}
+	return fmt.Sprintf("0x%x/0x%x", mark, mask)
+}
+
+// parseMark parses a mark in the <mark>[/<mask>] format. Both parts can be decimal or hex, the mask is zero when it
+// isn't present or covers the whole mark
+func parseMark(value string) (mark int, mask int, err error) {
+	mark, mask, err = parseRawMark(value)
+	if mask == markMaskAll {
+		mask = 0
+	}
+	return mark, mask, err
+}
+
+// parseRawMark parses a mark in the <mark>[/<mask>] format returning the mask as written, a missing mask covers the
+// whole mark
+func parseRawMark(value string) (mark int, mask int, err error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+	v, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid mark %s: %v", value, err)
+	}
+	mark = int(v)
+	mask = markMaskAll
+	if len(parts) == 2 {
+		v, err = strconv.ParseUint(parts[1], 0, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid mark mask %s: %v", value, err)
+		}
+		mask = int(v)
+	}
+	return mark, mask, nil
+}
+
+// validateMark checks that the mark and mask fit in the 32 bit netfilter mark
+func validateMark(kind string, mark int, mask int) error {
+	if mark < 0 || mark > markMaskAll {
+		return fmt.Errorf("%s value 0x%x is out of range", kind, mark)
+	}
+	if mask < 0 || mask > markMaskAll {
+		return fmt.Errorf("%s mask 0x%x is out of range", kind, mask)
+	}
+	return nil
+}
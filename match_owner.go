@@ -0,0 +1,172 @@
+package iptables
+
+import (
+	"fmt"
+	"os/user"
+	"regexp"
+)
+
+const (
+	matchOwnerName = "owner"
+)
+
+var (
+	idRangeRegex = regexp.MustCompile(`^[0-9]+(-[0-9]+)?$`)
+)
+
+// NewMatchOwner returns an owner match for the user, which can be a user name, uid or range of uids
+func NewMatchOwner(uid string) *MatchOwner {
+	return &MatchOwner{
+		Uid: uid,
+	}
+}
+
+// MatchOwner matches locally generated packets on the owner of the socket that sent them. Uid and Gid can be a
+// name, an id or a range of ids in the <min>-<max> format. Names are resolved to ids when the rule is written so
+// rules read back from iptables always contain ids. SupplGroups also matches the supplementary groups of the owner
+type MatchOwner struct {
+	Uid                 string `json:"uid,omitempty" yaml:"uid" xml:"uid"`
+	UidNegated          bool   `json:"uid_negated,omitempty" yaml:"uid_negated" xml:"uid_negated"`
+	Gid                 string `json:"gid,omitempty" yaml:"gid" xml:"gid"`
+	GidNegated          bool   `json:"gid_negated,omitempty" yaml:"gid_negated" xml:"gid_negated"`
+	SupplGroups         bool   `json:"suppl_groups,omitempty" yaml:"suppl_groups" xml:"suppl_groups"`
+	SocketExists        bool   `json:"socket_exists,omitempty" yaml:"socket_exists" xml:"socket_exists"`
+	SocketExistsNegated bool   `json:"socket_exists_negated,omitempty" yaml:"socket_exists_negated" xml:"socket_exists_negated"`
+}
+
+func (m MatchOwner) Name() string {
+	return matchOwnerName
+}
+
+func (m *MatchOwner) SetName(name string) error {
+	return fmt.Errorf("owner match doesn't support setting the name")
+}
+
+// Option returns the first option that is set on the match
+func (m MatchOwner) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchOwner) SetOption(option string) error {
+	return fmt.Errorf("owner match doesn't support setting the option")
+}
+
+// Value returns the value of the first option that is set on the match
+func (m MatchOwner) Value() string {
+	return firstOption(&m).Value
+}
+
+// SetValue sets the uid
+func (m *MatchOwner) SetValue(value string) error {
+	m.Uid = value
+	return nil
+}
+
+// Negated returns if the uid is negated
+func (m MatchOwner) Negated() bool {
+	return m.UidNegated
+}
+
+// SetNegated sets if the uid is negated
+func (m *MatchOwner) SetNegated(negated bool) {
+	m.UidNegated = negated
+}
+
+// Options returns the options of the match in the order iptables prints them. Names that can't be resolved are
+// passed through unchanged
+func (m MatchOwner) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.SocketExists {
+		options = append(options, MatchOption{Option: "socket-exists", Negated: m.SocketExistsNegated})
+	}
+	if m.Uid != "" {
+		uid, err := resolveUid(m.Uid)
+		if err != nil {
+			uid = m.Uid
+		}
+		options = append(options, MatchOption{Option: "uid-owner", Value: quoteArg(uid), Negated: m.UidNegated})
+	}
+	if m.Gid != "" {
+		gid, err := resolveGid(m.Gid)
+		if err != nil {
+			gid = m.Gid
+		}
+		options = append(options, MatchOption{Option: "gid-owner", Value: quoteArg(gid), Negated: m.GidNegated})
+	}
+	if m.SupplGroups {
+		options = append(options, MatchOption{Option: "suppl-groups"})
+	}
+	return options
+}
+
+func (m *MatchOwner) Parse(options []MatchOption) error {
+	for _, option := range options {
+		switch option.Option {
+		case "uid-owner":
+			m.Uid = unquoteArg(option.Value)
+			m.UidNegated = option.Negated
+		case "gid-owner":
+			m.Gid = unquoteArg(option.Value)
+			m.GidNegated = option.Negated
+		case "suppl-groups":
+			m.SupplGroups = true
+		case "socket-exists":
+			m.SocketExists = true
+			m.SocketExistsNegated = option.Negated
+		default:
+			return fmt.Errorf("unsupported owner option --%s", option.Option)
+		}
+	}
+	return nil
+}
+
+func (m MatchOwner) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchOwner) Validate(rule Rule) error {
+	if len(m.Options()) == 0 {
+		return fmt.Errorf("owner match requires at least one option")
+	}
+	if err := validateMatchChain("owner match", rule, ChainOutput, ChainPostRouting); err != nil {
+		return err
+	}
+	if m.Uid != "" {
+		if _, err := resolveUid(m.Uid); err != nil {
+			return fmt.Errorf("owner match %v", err)
+		}
+	}
+	if m.Gid != "" {
+		if _, err := resolveGid(m.Gid); err != nil {
+			return fmt.Errorf("owner match %v", err)
+		}
+	}
+	if m.SupplGroups && m.Gid == "" {
+		return fmt.Errorf("owner match suppl groups requires a gid")
+	}
+	return nil
+}
+
+// resolveUid returns the uid of the user name or the value unchanged if it is already a uid or range of uids
+func resolveUid(value string) (string, error) {
+	if idRangeRegex.MatchString(value) {
+		return value, nil
+	}
+	u, err := user.Lookup(value)
+	if err != nil {
+		return "", err
+	}
+	return u.Uid, nil
+}
+
+// resolveGid returns the gid of the group name or the value unchanged if it is already a gid or range of gids
+func resolveGid(value string) (string, error) {
+	if idRangeRegex.MatchString(value) {
+		return value, nil
+	}
+	g, err := user.LookupGroup(value)
+	if err != nil {
+		return "", err
+	}
+	return g.Gid, nil
+}
@@ -0,0 +1,131 @@
+package iptables
+
+import (
+	"fmt"
+)
+
+const (
+	matchPhysDevName = "physdev"
+)
+
+// MatchPhysDev matches on the bridge ports a bridged packet arrived on or is leaving through. The output port
+// options are only valid on the FORWARD, OUTPUT and POSTROUTING chains. On OUTPUT they also require IsBridged, like
+// the kernel does, as the output port of a locally generated packet is only known once it is bridged
+type MatchPhysDev struct {
+	In               string `json:"in,omitempty" yaml:"in" xml:"in"`
+	InNegated        bool   `json:"in_negated,omitempty" yaml:"in_negated" xml:"in_negated"`
+	Out              string `json:"out,omitempty" yaml:"out" xml:"out"`
+	OutNegated       bool   `json:"out_negated,omitempty" yaml:"out_negated" xml:"out_negated"`
+	IsIn             bool   `json:"is_in,omitempty" yaml:"is_in" xml:"is_in"`
+	IsInNegated      bool   `json:"is_in_negated,omitempty" yaml:"is_in_negated" xml:"is_in_negated"`
+	IsOut            bool   `json:"is_out,omitempty" yaml:"is_out" xml:"is_out"`
+	IsOutNegated     bool   `json:"is_out_negated,omitempty" yaml:"is_out_negated" xml:"is_out_negated"`
+	IsBridged        bool   `json:"is_bridged,omitempty" yaml:"is_bridged" xml:"is_bridged"`
+	IsBridgedNegated bool   `json:"is_bridged_negated,omitempty" yaml:"is_bridged_negated" xml:"is_bridged_negated"`
+}
+
+func (m MatchPhysDev) Name() string {
+	return matchPhysDevName
+}
+
+func (m *MatchPhysDev) SetName(name string) error {
+	return fmt.Errorf("physdev match doesn't support setting the name")
+}
+
+// Option returns the first option that is set on the match
+func (m MatchPhysDev) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchPhysDev) SetOption(option string) error {
+	return fmt.Errorf("physdev match doesn't support setting the option")
+}
+
+// Value returns the value of the first option that is set on the match
+func (m MatchPhysDev) Value() string {
+	return firstOption(&m).Value
+}
+
+func (m *MatchPhysDev) SetValue(value string) error {
+	return fmt.Errorf("physdev match doesn't support setting the value")
+}
+
+// Negated returns if the first option that is set on the match is negated
+func (m MatchPhysDev) Negated() bool {
+	return firstOption(&m).Negated
+}
+
+// SetNegated does nothing as each option is negated on its own, use the negated fields instead
+func (m *MatchPhysDev) SetNegated(negated bool) {
+
+}
+
+// Options returns the options of the match in the order iptables prints them
+func (m MatchPhysDev) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.IsIn {
+		options = append(options, MatchOption{Option: "physdev-is-in", Negated: m.IsInNegated})
+	}
+	if m.In != "" {
+		options = append(options, MatchOption{Option: "physdev-in", Value: m.In, Negated: m.InNegated})
+	}
+	if m.IsOut {
+		options = append(options, MatchOption{Option: "physdev-is-out", Negated: m.IsOutNegated})
+	}
+	if m.Out != "" {
+		options = append(options, MatchOption{Option: "physdev-out", Value: m.Out, Negated: m.OutNegated})
+	}
+	if m.IsBridged {
+		options = append(options, MatchOption{Option: "physdev-is-bridged", Negated: m.IsBridgedNegated})
+	}
+	return options
+}
+
+func (m *MatchPhysDev) Parse(options []MatchOption) error {
+	for _, option := range options {
+		switch option.Option {
+		case "physdev-in":
+			m.In = option.Value
+			m.InNegated = option.Negated
+		case "physdev-out":
+			m.Out = option.Value
+			m.OutNegated = option.Negated
+		case "physdev-is-in":
+			m.IsIn = true
+			m.IsInNegated = option.Negated
+		case "physdev-is-out":
+			m.IsOut = true
+			m.IsOutNegated = option.Negated
+		case "physdev-is-bridged":
+			m.IsBridged = true
+			m.IsBridgedNegated = option.Negated
+		default:
+			return fmt.Errorf("unsupported physdev option --%s", option.Option)
+		}
+	}
+	return nil
+}
+
+func (m MatchPhysDev) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchPhysDev) Validate(rule Rule) error {
+	if len(m.Options()) == 0 {
+		return fmt.Errorf("physdev match requires at least one option")
+	}
+
+	if m.Out == "" && !m.IsOut {
+		return nil
+	}
+	switch rule.Chain {
+	case ChainInput, ChainPreRouting:
+		return fmt.Errorf("physdev match output options are only valid on the FORWARD, OUTPUT and POSTROUTING chains")
+	case ChainOutput:
+		// The kernel only knows the output bridge port of locally generated packets once they are bridged
+		if !m.IsBridged || m.IsBridgedNegated {
+			return fmt.Errorf("physdev match output options on the %s chain require --physdev-is-bridged", rule.Chain)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package iptables
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	matchPktTypeName   = "pkttype"
+	matchPktTypeOption = "pkt-type"
+)
+
+// PktType is the link layer packet type
+type PktType string
+
+const (
+	PktTypeUnicast   PktType = "unicast"
+	PktTypeBroadcast PktType = "broadcast"
+	PktTypeMulticast PktType = "multicast"
+)
+
+// NewMatchPktType returns a pkttype match for the packet type
+func NewMatchPktType(pktType PktType) *MatchPktType {
+	return &MatchPktType{
+		PktType: pktType,
+	}
+}
+
+// MatchPktType matches on the link layer packet type
+type MatchPktType struct {
+	PktType        PktType `json:"pkt_type" yaml:"pkt_type" xml:"pkt_type"`
+	PktTypeNegated bool    `json:"pkt_type_negated,omitempty" yaml:"pkt_type_negated" xml:"pkt_type_negated"`
+}
+
+func (m MatchPktType) Name() string {
+	return matchPktTypeName
+}
+
+func (m *MatchPktType) SetName(name string) error {
+	return fmt.Errorf("pkttype match doesn't support setting the name")
+}
+
+func (m MatchPktType) Option() string {
+	return matchPktTypeOption
+}
+
+func (m *MatchPktType) SetOption(option string) error {
+	return fmt.Errorf("pkttype match doesn't support setting the option")
+}
+
+func (m MatchPktType) Value() string {
+	return string(m.PktType)
+}
+
+func (m *MatchPktType) SetValue(value string) error {
+	m.PktType = PktType(strings.ToLower(value))
+	return nil
+}
+
+func (m MatchPktType) Negated() bool {
+	return m.PktTypeNegated
+}
+
+func (m *MatchPktType) SetNegated(negated bool) {
+	m.PktTypeNegated = negated
+}
+
+func (m MatchPktType) Options() []MatchOption {
+	return []MatchOption{{Option: matchPktTypeOption, Value: m.Value(), Negated: m.PktTypeNegated}}
+}
+
+func (m *MatchPktType) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchPktTypeOption {
+			return fmt.Errorf("unsupported pkttype option --%s", option.Option)
+		}
+		if err := m.SetValue(option.Value); err != nil {
+			return err
+		}
+		m.PktTypeNegated = option.Negated
+	}
+	return nil
+}
+
+func (m MatchPktType) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchPktType) Validate(rule Rule) error {
+	switch m.PktType {
+	case PktTypeUnicast, PktTypeBroadcast, PktTypeMulticast:
+		return nil
+	}
+	return fmt.Errorf("invalid pkttype match type '%s'. expected unicast, broadcast or multicast", m.PktType)
+}
@@ -0,0 +1,206 @@
+package iptables
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	matchRecentName = "recent"
+
+	// recentNameMaxLength is the longest list name the kernel accepts
+	recentNameMaxLength = 199
+)
+
+// RecentMode is the action the recent match takes with the address of the packet
+type RecentMode string
+
+const (
+	RecentModeSet    RecentMode = "set"
+	RecentModeCheck  RecentMode = "rcheck"
+	RecentModeUpdate RecentMode = "update"
+	RecentModeRemove RecentMode = "remove"
+)
+
+// RecentSide selects which address of the packet is used by the recent match
+type RecentSide string
+
+const (
+	RecentSideSource      RecentSide = "rsource"
+	RecentSideDestination RecentSide = "rdest"
+)
+
+// NewMatchRecent returns a recent match for the list using the mode
+func NewMatchRecent(list string, mode RecentMode) *MatchRecent {
+	return &MatchRecent{
+		List: list,
+		Mode: mode,
+	}
+}
+
+// MatchRecent adds addresses to, checks addresses against or removes addresses from a list of recently seen
+// addresses. Seconds, Reap, HitCount and RTTL limit the check and update modes to addresses seen within the last
+// Seconds at least HitCount times. An empty List uses the DEFAULT list and an empty Side uses the source address
+type MatchRecent struct {
+	List        string     `json:"list,omitempty" yaml:"list" xml:"list"`
+	Mode        RecentMode `json:"mode" yaml:"mode" xml:"mode"`
+	ModeNegated bool       `json:"mode_negated,omitempty" yaml:"mode_negated" xml:"mode_negated"`
+	Seconds     int        `json:"seconds,omitempty" yaml:"seconds" xml:"seconds"`
+	Reap        bool       `json:"reap,omitempty" yaml:"reap" xml:"reap"`
+	HitCount    int        `json:"hit_count,omitempty" yaml:"hit_count" xml:"hit_count"`
+	RTTL        bool       `json:"rttl,omitempty" yaml:"rttl" xml:"rttl"`
+	Mask        string     `json:"mask,omitempty" yaml:"mask" xml:"mask"`
+	Side        RecentSide `json:"side,omitempty" yaml:"side" xml:"side"`
+}
+
+func (m MatchRecent) Name() string {
+	return matchRecentName
+}
+
+func (m *MatchRecent) SetName(name string) error {
+	return fmt.Errorf("recent match doesn't support setting the name")
+}
+
+// Option returns the mode of the match
+func (m MatchRecent) Option() string {
+	return string(m.Mode)
+}
+
+// SetOption sets the mode of the match
+func (m *MatchRecent) SetOption(option string) error {
+	m.Mode = RecentMode(option)
+	return nil
+}
+
+// Value returns the list name
+func (m MatchRecent) Value() string {
+	return m.List
+}
+
+// SetValue sets the list name
+func (m *MatchRecent) SetValue(value string) error {
+	m.List = value
+	return nil
+}
+
+// Negated returns if the mode is negated
+func (m MatchRecent) Negated() bool {
+	return m.ModeNegated
+}
+
+// SetNegated sets if the mode is negated
+func (m *MatchRecent) SetNegated(negated bool) {
+	m.ModeNegated = negated
+}
+
+// Options returns the options of the match in the order iptables prints them
+func (m MatchRecent) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.Mode != "" {
+		options = append(options, MatchOption{Option: string(m.Mode), Negated: m.ModeNegated})
+	}
+	if m.Seconds > 0 {
+		options = append(options, MatchOption{Option: "seconds", Value: strconv.Itoa(m.Seconds)})
+	}
+	if m.Reap {
+		options = append(options, MatchOption{Option: "reap"})
+	}
+	if m.HitCount > 0 {
+		options = append(options, MatchOption{Option: "hitcount", Value: strconv.Itoa(m.HitCount)})
+	}
+	if m.RTTL {
+		options = append(options, MatchOption{Option: "rttl"})
+	}
+	if m.List != "" {
+		options = append(options, MatchOption{Option: "name", Value: quoteArg(m.List)})
+	}
+	if m.Mask != "" {
+		options = append(options, MatchOption{Option: "mask", Value: m.Mask})
+	}
+	if m.Side != "" {
+		options = append(options, MatchOption{Option: string(m.Side)})
+	}
+	return options
+}
+
+func (m *MatchRecent) Parse(options []MatchOption) (err error) {
+	for _, option := range options {
+		switch option.Option {
+		case string(RecentModeSet), string(RecentModeCheck), string(RecentModeUpdate), string(RecentModeRemove):
+			m.Mode = RecentMode(option.Option)
+			m.ModeNegated = option.Negated
+		case "seconds":
+			m.Seconds, err = strconv.Atoi(option.Value)
+		case "reap":
+			m.Reap = true
+		case "hitcount":
+			m.HitCount, err = strconv.Atoi(option.Value)
+		case "rttl":
+			m.RTTL = true
+		case "name":
+			m.List = unquoteArg(option.Value)
+		case "mask":
+			m.Mask = option.Value
+		case string(RecentSideSource), string(RecentSideDestination):
+			m.Side = RecentSide(option.Option)
+		default:
+			return fmt.Errorf("unsupported recent option --%s", option.Option)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for --%s: %v", option.Option, err)
+		}
+	}
+	return nil
+}
+
+func (m MatchRecent) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchRecent) Validate(rule Rule) error {
+	switch m.Mode {
+	case RecentModeSet, RecentModeCheck, RecentModeUpdate, RecentModeRemove:
+	default:
+		return fmt.Errorf("invalid recent match mode '%s'. expected set, rcheck, update or remove", m.Mode)
+	}
+	if m.List != "" {
+		if err := validateRecentList(m.List); err != nil {
+			return err
+		}
+	}
+	if m.Mode != RecentModeCheck && m.Mode != RecentModeUpdate && (m.Seconds != 0 || m.Reap || m.HitCount != 0 || m.RTTL) {
+		return fmt.Errorf("recent match seconds, reap, hitcount and rttl are only valid with rcheck and update")
+	}
+	if m.Seconds < 0 || m.HitCount < 0 {
+		return fmt.Errorf("recent match seconds and hitcount can't be negative")
+	}
+	if m.Reap && m.Seconds == 0 {
+		return fmt.Errorf("recent match reap requires seconds")
+	}
+	if m.Mask != "" {
+		ip := net.ParseIP(m.Mask)
+		if ip == nil {
+			return fmt.Errorf("invalid recent match mask %s", m.Mask)
+		}
+		if err := validateAddressFamily("recent match mask", ip, rule.IpVersion); err != nil {
+			return err
+		}
+	}
+	if m.Side != "" && m.Side != RecentSideSource && m.Side != RecentSideDestination {
+		return fmt.Errorf("invalid recent match side %s. expected rsource or rdest", m.Side)
+	}
+	return nil
+}
+
+// validateRecentList checks that the name can be used as a list name, which is also a file name in /proc
+func validateRecentList(name string) error {
+	if name == "" || len(name) > recentNameMaxLength {
+		return fmt.Errorf("recent list name must be between 1 and %d characters", recentNameMaxLength)
+	}
+	if strings.ContainsAny(name, "/ \t\r\n\"\\'") || name == "." || name == ".." {
+		return fmt.Errorf("invalid recent list name '%s'", name)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package iptables
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	matchSetName   = "set"
+	matchSetOption = "match-set"
+)
+
+// SetDirection selects which address or port of the packet is looked up in a set
+type SetDirection string
+
+const (
+	SetDirectionSource      SetDirection = "src"
+	SetDirectionDestination SetDirection = "dst"
+)
+
+// NewMatchSet returns a set match for the named set looked up using the passed in directions
+func NewMatchSet(setName string, directions ...SetDirection) *MatchSet {
+	return &MatchSet{
+		Set:        setName,
+		Directions: directions,
+	}
+}
+
+// MatchSet matches packets against an ipset set. Each direction selects the packet field used for the matching
+// dimension of the set, for example a hash:ip,port set matched with src,dst uses the source address and the
+// destination port
+type MatchSet struct {
+	Set          string         `json:"set" yaml:"set" xml:"set"`
+	Directions   []SetDirection `json:"directions" yaml:"directions" xml:"directions"`
+	MatchNegated bool           `json:"match_negated,omitempty" yaml:"match_negated" xml:"match_negated"`
+}
+
+func (m MatchSet) Name() string {
+	return matchSetName
+}
+
+func (m *MatchSet) SetName(name string) error {
+	return fmt.Errorf("set match doesn't support setting the name")
+}
+
+func (m MatchSet) Option() string {
+	return matchSetOption
+}
+
+func (m *MatchSet) SetOption(option string) error {
+	return fmt.Errorf("set match doesn't support setting the option")
+}
+
+func (m MatchSet) Value() string {
+	return fmt.Sprintf("%s %s", m.Set, joinSetDirections(m.Directions))
+}
+
+// SetValue sets the set name and directions from the '<name> <direction>[,<direction>...]' format
+func (m *MatchSet) SetValue(value string) error {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return fmt.Errorf("invalid set match format. expected <name> <flags> got %s", value)
+	}
+	m.Set = fields[0]
+	m.Directions = splitSetDirections(fields[1])
+	return nil
+}
+
+func (m MatchSet) Negated() bool {
+	return m.MatchNegated
+}
+
+func (m *MatchSet) SetNegated(negated bool) {
+	m.MatchNegated = negated
+}
+
+func (m MatchSet) Options() []MatchOption {
+	return []MatchOption{{Option: matchSetOption, Value: m.Value(), Negated: m.MatchNegated}}
+}
+
+func (m *MatchSet) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchSetOption {
+			return fmt.Errorf("unsupported set option --%s", option.Option)
+		}
+		if err := m.SetValue(option.Value); err != nil {
+			return err
+		}
+		m.MatchNegated = option.Negated
+	}
+	return nil
+}
+
+func (m MatchSet) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchSet) Validate(rule Rule) error {
+	return validateSetReference(matchSetName+" match", m.Set, m.Directions)
+}
+
+// validateSetReference checks the set name and directions used by the set match and target
+func validateSetReference(kind string, name string, directions []SetDirection) error {
+	if name == "" {
+		return fmt.Errorf("%s requires a set name", kind)
+	}
+	if len(directions) == 0 || len(directions) > 6 {
+		return fmt.Errorf("%s requires between 1 and 6 directions", kind)
+	}
+	for _, direction := range directions {
+		if direction != SetDirectionSource && direction != SetDirectionDestination {
+			return fmt.Errorf("invalid %s direction %s. expected src or dst", kind, direction)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package iptables
+
+import (
+	"fmt"
+)
+
+const (
+	matchSocketName = "socket"
+)
+
+// MatchSocket matches packets that belong to an existing local socket. Transparent only matches transparent
+// sockets, NoWildcard ignores sockets bound to the wildcard address and RestoreSkMark copies the socket mark to the
+// packet
+type MatchSocket struct {
+	Transparent   bool `json:"transparent,omitempty" yaml:"transparent" xml:"transparent"`
+	NoWildcard    bool `json:"no_wildcard,omitempty" yaml:"no_wildcard" xml:"no_wildcard"`
+	RestoreSkMark bool `json:"restore_sk_mark,omitempty" yaml:"restore_sk_mark" xml:"restore_sk_mark"`
+}
+
+func (m MatchSocket) Name() string {
+	return matchSocketName
+}
+
+func (m *MatchSocket) SetName(name string) error {
+	return fmt.Errorf("socket match doesn't support setting the name")
+}
+
+func (m MatchSocket) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchSocket) SetOption(option string) error {
+	return fmt.Errorf("socket match doesn't support setting the option")
+}
+
+func (m MatchSocket) Value() string {
+	return ""
+}
+
+func (m *MatchSocket) SetValue(value string) error {
+	return fmt.Errorf("socket match doesn't support setting the value")
+}
+
+func (m MatchSocket) Negated() bool {
+	return false
+}
+
+func (m *MatchSocket) SetNegated(negated bool) {
+
+}
+
+func (m MatchSocket) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.Transparent {
+		options = append(options, MatchOption{Option: "transparent"})
+	}
+	if m.NoWildcard {
+		options = append(options, MatchOption{Option: "nowildcard"})
+	}
+	if m.RestoreSkMark {
+		options = append(options, MatchOption{Option: "restore-skmark"})
+	}
+	return options
+}
+
+func (m *MatchSocket) Parse(options []MatchOption) error {
+	for _, option := range options {
+		switch option.Option {
+		case "transparent":
+			m.Transparent = true
+		case "nowildcard":
+			m.NoWildcard = true
+		case "restore-skmark":
+			m.RestoreSkMark = true
+		default:
+			return fmt.Errorf("unsupported socket option --%s", option.Option)
+		}
+	}
+	return nil
+}
+
+func (m MatchSocket) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchSocket) Validate(rule Rule) error {
+	return validateMatchChain("socket match", rule, ChainPreRouting, ChainInput)
+}
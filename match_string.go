@@ -0,0 +1,181 @@
+package iptables
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	matchStringName = "string"
+
+	// matchStringMaxPattern is the longest pattern the kernel accepts
+	matchStringMaxPattern = 128
+)
+
+// StringAlgo is the search algorithm used by the string match
+type StringAlgo string
+
+const (
+	StringAlgoBM  StringAlgo = "bm"
+	StringAlgoKMP StringAlgo = "kmp"
+)
+
+// NewMatchString returns a string match for the pattern using the Boyer-Moore algorithm
+func NewMatchString(pattern string) *MatchString {
+	return &MatchString{
+		Pattern: pattern,
+		Algo:    StringAlgoBM,
+	}
+}
+
+// MatchString matches packets that contain the pattern. The pattern is raw bytes, patterns that contain anything
+// other than printable ASCII are written as --hex-string. From and To limit the search to a range of offsets
+// within the packet, zero leaves them unset
+type MatchString struct {
+	Pattern        string     `json:"pattern" yaml:"pattern" xml:"pattern"`
+	PatternNegated bool       `json:"pattern_negated,omitempty" yaml:"pattern_negated" xml:"pattern_negated"`
+	Algo           StringAlgo `json:"algo" yaml:"algo" xml:"algo"`
+	From           int        `json:"from,omitempty" yaml:"from" xml:"from"`
+	To             int        `json:"to,omitempty" yaml:"to" xml:"to"`
+	IgnoreCase     bool       `json:"ignore_case,omitempty" yaml:"ignore_case" xml:"ignore_case"`
+}
+
+func (m MatchString) Name() string {
+	return matchStringName
+}
+
+func (m *MatchString) SetName(name string) error {
+	return fmt.Errorf("string match doesn't support setting the name")
+}
+
+// Option returns either string or hex-string depending on the pattern
+func (m MatchString) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchString) SetOption(option string) error {
+	return fmt.Errorf("string match doesn't support setting the option")
+}
+
+func (m MatchString) Value() string {
+	return m.Pattern
+}
+
+// SetValue sets the pattern
+func (m *MatchString) SetValue(value string) error {
+	m.Pattern = value
+	return nil
+}
+
+func (m MatchString) Negated() bool {
+	return m.PatternNegated
+}
+
+func (m *MatchString) SetNegated(negated bool) {
+	m.PatternNegated = negated
+}
+
+// Options returns the options of the match in the order iptables prints them
+func (m MatchString) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if isHexPattern(m.Pattern) {
+		value := fmt.Sprintf("\"|%s|\"", hex.EncodeToString([]byte(m.Pattern)))
+		options = append(options, MatchOption{Option: "hex-string", Value: value, Negated: m.PatternNegated})
+	} else {
+		options = append(options, MatchOption{Option: "string", Value: quoteString(m.Pattern, "\"\\"), Negated: m.PatternNegated})
+	}
+	options = append(options, MatchOption{Option: "algo", Value: string(m.Algo)})
+	if m.From != 0 {
+		options = append(options, MatchOption{Option: "from", Value: strconv.Itoa(m.From)})
+	}
+	if m.To != 0 {
+		options = append(options, MatchOption{Option: "to", Value: strconv.Itoa(m.To)})
+	}
+	if m.IgnoreCase {
+		options = append(options, MatchOption{Option: "icase"})
+	}
+	return options
+}
+
+func (m *MatchString) Parse(options []MatchOption) (err error) {
+	for _, option := range options {
+		switch option.Option {
+		case "string":
+			m.Pattern = unquoteArg(option.Value)
+			m.PatternNegated = option.Negated
+		case "hex-string":
+			m.Pattern, err = parseHexPattern(unquoteArg(option.Value))
+			m.PatternNegated = option.Negated
+		case "algo":
+			m.Algo = StringAlgo(option.Value)
+		case "from":
+			m.From, err = strconv.Atoi(option.Value)
+		case "to":
+			m.To, err = strconv.Atoi(option.Value)
+		case "icase":
+			m.IgnoreCase = true
+		default:
+			return fmt.Errorf("unsupported string option --%s", option.Option)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for --%s: %v", option.Option, err)
+		}
+	}
+	return nil
+}
+
+func (m MatchString) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchString) Validate(rule Rule) error {
+	if m.Pattern == "" {
+		return fmt.Errorf("string match requires a pattern")
+	}
+	if len(m.Pattern) > matchStringMaxPattern {
+		return fmt.Errorf("string match pattern is longer than %d bytes", matchStringMaxPattern)
+	}
+	if m.Algo != StringAlgoBM && m.Algo != StringAlgoKMP {
+		return fmt.Errorf("invalid string match algo %s. expected bm or kmp", m.Algo)
+	}
+	if m.From < 0 || m.From > 65535 || m.To < 0 || m.To > 65535 {
+		return fmt.Errorf("string match offsets must be between 0 and 65535")
+	}
+	if m.To != 0 && m.To < m.From {
+		return fmt.Errorf("string match to offset is before the from offset")
+	}
+	return nil
+}
+
+// isHexPattern returns true if the pattern contains bytes iptables prints using --hex-string
+func isHexPattern(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] < 0x20 || pattern[i] > 0x7e {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHexPattern decodes a --hex-string pattern. Bytes between pipes are written in hex, optionally separated by
+// spaces, and everything outside of the pipes is used as is
+func parseHexPattern(value string) (string, error) {
+	var pattern strings.Builder
+	for i, part := range strings.Split(value, "|") {
+		if i%2 == 0 {
+			pattern.WriteString(part)
+			continue
+		}
+		decoded, err := hex.DecodeString(strings.ReplaceAll(part, " ", ""))
+		if err != nil {
+			return "", err
+		}
+		pattern.Write(decoded)
+	}
+	if strings.Count(value, "|")%2 != 0 {
+		return "", fmt.Errorf("unterminated hex block in %s", value)
+	}
+	return pattern.String(), nil
+}
@@ -0,0 +1,226 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	matchTCPName = "tcp"
+)
+
+// TCPFlag is a flag in the TCP header
+type TCPFlag string
+
+const (
+	TCPFlagSYN  TCPFlag = "SYN"
+	TCPFlagACK  TCPFlag = "ACK"
+	TCPFlagFIN  TCPFlag = "FIN"
+	TCPFlagRST  TCPFlag = "RST"
+	TCPFlagURG  TCPFlag = "URG"
+	TCPFlagPSH  TCPFlag = "PSH"
+	TCPFlagALL  TCPFlag = "ALL"
+	TCPFlagNONE TCPFlag = "NONE"
+)
+
+// MatchTCP matches on the TCP header. FlagsMask lists the flags that are examined and FlagsSet the ones of those
+// that must be set. Syn is shorthand for SYN,RST,ACK,FIN SYN. TCPOption matches packets that carry the TCP option
+// number when TCPOptionSet is true. When the match is read from a rule the ports are kept on the rule itself
+type MatchTCP struct {
+	SourcePort             string    `json:"source_port,omitempty" yaml:"source_port" xml:"source_port"`
+	SourcePortNegated      bool      `json:"source_port_negated,omitempty" yaml:"source_port_negated" xml:"source_port_negated"`
+	DestinationPort        string    `json:"destination_port,omitempty" yaml:"destination_port" xml:"destination_port"`
+	DestinationPortNegated bool      `json:"destination_port_negated,omitempty" yaml:"destination_port_negated" xml:"destination_port_negated"`
+	FlagsMask              []TCPFlag `json:"flags_mask,omitempty" yaml:"flags_mask" xml:"flags_mask"`
+	FlagsSet               []TCPFlag `json:"flags_set,omitempty" yaml:"flags_set" xml:"flags_set"`
+	FlagsNegated           bool      `json:"flags_negated,omitempty" yaml:"flags_negated" xml:"flags_negated"`
+	Syn                    bool      `json:"syn,omitempty" yaml:"syn" xml:"syn"`
+	SynNegated             bool      `json:"syn_negated,omitempty" yaml:"syn_negated" xml:"syn_negated"`
+	TCPOption              int       `json:"tcp_option,omitempty" yaml:"tcp_option" xml:"tcp_option"`
+	TCPOptionSet           bool      `json:"tcp_option_set,omitempty" yaml:"tcp_option_set" xml:"tcp_option_set"`
+	TCPOptionNegated       bool      `json:"tcp_option_negated,omitempty" yaml:"tcp_option_negated" xml:"tcp_option_negated"`
+}
+
+// IsSyn returns true if the match only matches packets with the SYN flag set
+func (m MatchTCP) IsSyn() bool {
+	if m.Syn {
+		return !m.SynNegated
+	}
+	return !m.FlagsNegated && containsTCPFlag(m.FlagsMask, TCPFlagSYN) && containsTCPFlag(m.FlagsSet, TCPFlagSYN)
+}
+
+func (m MatchTCP) Name() string {
+	return matchTCPName
+}
+
+func (m *MatchTCP) SetName(name string) error {
+	return fmt.Errorf("tcp match doesn't support setting the name")
+}
+
+// Option returns the first option that is set on the match
+func (m MatchTCP) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchTCP) SetOption(option string) error {
+	return fmt.Errorf("tcp match doesn't support setting the option")
+}
+
+// Value returns the value of the first option that is set on the match
+func (m MatchTCP) Value() string {
+	return firstOption(&m).Value
+}
+
+func (m *MatchTCP) SetValue(value string) error {
+	return fmt.Errorf("tcp match doesn't support setting the value")
+}
+
+// Negated returns if the first option that is set on the match is negated
+func (m MatchTCP) Negated() bool {
+	return firstOption(&m).Negated
+}
+
+// SetNegated does nothing as each option is negated on its own, use the negated fields instead
+func (m *MatchTCP) SetNegated(negated bool) {
+
+}
+
+// Options returns the options of the match in the order iptables prints them
+func (m MatchTCP) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.SourcePort != "" {
+		options = append(options, MatchOption{Option: "sport", Value: m.SourcePort, Negated: m.SourcePortNegated})
+	}
+	if m.DestinationPort != "" {
+		options = append(options, MatchOption{Option: "dport", Value: m.DestinationPort, Negated: m.DestinationPortNegated})
+	}
+	if m.TCPOptionSet {
+		options = append(options, MatchOption{Option: "tcp-option", Value: strconv.Itoa(m.TCPOption), Negated: m.TCPOptionNegated})
+	}
+	if len(m.FlagsMask) > 0 {
+		value := fmt.Sprintf("%s %s", joinTCPFlags(m.FlagsMask), joinTCPFlags(m.FlagsSet))
+		options = append(options, MatchOption{Option: "tcp-flags", Value: value, Negated: m.FlagsNegated})
+	}
+	if m.Syn {
+		options = append(options, MatchOption{Option: "syn", Negated: m.SynNegated})
+	}
+	return options
+}
+
+func (m *MatchTCP) Parse(options []MatchOption) (err error) {
+	for _, option := range options {
+		switch option.Option {
+		case "sport", "source-port":
+			m.SourcePort = option.Value
+			m.SourcePortNegated = option.Negated
+		case "dport", "destination-port":
+			m.DestinationPort = option.Value
+			m.DestinationPortNegated = option.Negated
+		case "tcp-option":
+			m.TCPOption, err = strconv.Atoi(option.Value)
+			m.TCPOptionSet = true
+			m.TCPOptionNegated = option.Negated
+		case "tcp-flags":
+			parts := strings.Fields(option.Value)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid value for --tcp-flags '%s'. expected <mask> <set>", option.Value)
+			}
+			m.FlagsMask = splitTCPFlags(parts[0])
+			m.FlagsSet = splitTCPFlags(parts[1])
+			m.FlagsNegated = option.Negated
+		case "syn":
+			m.Syn = true
+			m.SynNegated = option.Negated
+		default:
+			return fmt.Errorf("unsupported tcp option --%s", option.Option)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for --%s: %v", option.Option, err)
+		}
+	}
+	return nil
+}
+
+func (m MatchTCP) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchTCP) Validate(rule Rule) error {
+	if rule.Protocol != ProtocolTCP || rule.ProtocolNegated {
+		return fmt.Errorf("tcp match requires the protocol to be %s", ProtocolTCP)
+	}
+	if len(m.Options()) == 0 {
+		return fmt.Errorf("tcp match requires at least one option")
+	}
+	for _, port := range []string{m.SourcePort, m.DestinationPort} {
+		if port == "" {
+			continue
+		}
+		if err := validatePortRange(port); err != nil {
+			return fmt.Errorf("tcp match %v", err)
+		}
+	}
+	if m.Syn && len(m.FlagsMask) > 0 {
+		return fmt.Errorf("tcp match can't use both syn and tcp-flags")
+	}
+	if len(m.FlagsMask) == 0 && len(m.FlagsSet) > 0 {
+		return fmt.Errorf("tcp match flags set requires a flags mask")
+	}
+	for _, flag := range append(append([]TCPFlag{}, m.FlagsMask...), m.FlagsSet...) {
+		switch flag {
+		case TCPFlagSYN, TCPFlagACK, TCPFlagFIN, TCPFlagRST, TCPFlagURG, TCPFlagPSH, TCPFlagALL, TCPFlagNONE:
+		default:
+			return fmt.Errorf("invalid tcp match flag %s", flag)
+		}
+	}
+	if m.TCPOptionSet && (m.TCPOption < 0 || m.TCPOption > 255) {
+		return fmt.Errorf("tcp match option must be between 0 and 255")
+	}
+	return nil
+}
+
+// validatePortRange checks a port or a <first>:<last> range of ports
+func validatePortRange(value string) error {
+	parts := strings.Split(value, ":")
+	if len(parts) > 2 {
+		return fmt.Errorf("invalid port range %s. expected <port>[:<port>]", value)
+	}
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(part, 10, 16); err != nil {
+			return fmt.Errorf("invalid port range %s. expected <port>[:<port>]", value)
+		}
+	}
+	return nil
+}
+
+func containsTCPFlag(flags []TCPFlag, flag TCPFlag) bool {
+	for _, f := range flags {
+		if f == flag || f == TCPFlagALL {
+			return true
+		}
+	}
+	return false
+}
+
+func joinTCPFlags(flags []TCPFlag) string {
+	if len(flags) == 0 {
+		return string(TCPFlagNONE)
+	}
+	parts := make([]string, 0)
+	for _, flag := range flags {
+		parts = append(parts, string(flag))
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitTCPFlags(value string) []TCPFlag {
+	flags := make([]TCPFlag, 0)
+	for _, flag := range strings.Split(value, ",") {
+		flags = append(flags, TCPFlag(strings.ToUpper(flag)))
+	}
+	return flags
+}
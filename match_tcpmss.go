@@ -0,0 +1,88 @@
+package iptables
+
+import (
+	"fmt"
+)
+
+const (
+	matchTCPMSSName   = "tcpmss"
+	matchTCPMSSOption = "mss"
+)
+
+// NewMatchTCPMSS returns a tcpmss match for the MSS value or <min>:<max> range
+func NewMatchTCPMSS(mss string) *MatchTCPMSS {
+	return &MatchTCPMSS{
+		Mss: mss,
+	}
+}
+
+// MatchTCPMSS matches the MSS option of TCP SYN and SYN/ACK packets against a value or <min>:<max> range
+type MatchTCPMSS struct {
+	Mss        string `json:"mss" yaml:"mss" xml:"mss"`
+	MssNegated bool   `json:"mss_negated,omitempty" yaml:"mss_negated" xml:"mss_negated"`
+}
+
+func (m MatchTCPMSS) Name() string {
+	return matchTCPMSSName
+}
+
+func (m *MatchTCPMSS) SetName(name string) error {
+	return fmt.Errorf("tcpmss match doesn't support setting the name")
+}
+
+func (m MatchTCPMSS) Option() string {
+	return matchTCPMSSOption
+}
+
+func (m *MatchTCPMSS) SetOption(option string) error {
+	return fmt.Errorf("tcpmss match doesn't support setting the option")
+}
+
+func (m MatchTCPMSS) Value() string {
+	return m.Mss
+}
+
+func (m *MatchTCPMSS) SetValue(value string) error {
+	m.Mss = value
+	return nil
+}
+
+func (m MatchTCPMSS) Negated() bool {
+	return m.MssNegated
+}
+
+func (m *MatchTCPMSS) SetNegated(negated bool) {
+	m.MssNegated = negated
+}
+
+func (m MatchTCPMSS) Options() []MatchOption {
+	return []MatchOption{{Option: matchTCPMSSOption, Value: m.Mss, Negated: m.MssNegated}}
+}
+
+func (m *MatchTCPMSS) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchTCPMSSOption {
+			return fmt.Errorf("unsupported tcpmss option --%s", option.Option)
+		}
+		m.Mss = option.Value
+		m.MssNegated = option.Negated
+	}
+	return nil
+}
+
+func (m MatchTCPMSS) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchTCPMSS) Validate(rule Rule) error {
+	if rule.Protocol != ProtocolTCP || rule.ProtocolNegated {
+		return fmt.Errorf("tcpmss match requires the protocol to be %s", ProtocolTCP)
+	}
+	if m.Mss == "" {
+		return fmt.Errorf("tcpmss match requires an mss")
+	}
+	if err := validatePortRange(m.Mss); err != nil {
+		return fmt.Errorf("invalid tcpmss match mss %s. expected <value>[:<value>]", m.Mss)
+	}
+	return nil
+}
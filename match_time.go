@@ -0,0 +1,350 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	matchTimeName = "time"
+
+	matchTimeDateFormat = "2006-01-02T15:04:05"
+)
+
+var (
+	weekdayNames = map[time.Weekday]string{
+		time.Monday:    "Mon",
+		time.Tuesday:   "Tue",
+		time.Wednesday: "Wed",
+		time.Thursday:  "Thu",
+		time.Friday:    "Fri",
+		time.Saturday:  "Sat",
+		time.Sunday:    "Sun",
+	}
+	weekdayOrder = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+)
+
+// NewMatchTimeWindow returns a time match for the daily window between start and stop on the passed in days, every
+// day when no days are passed in. Only the time of day of start and stop is used. The UTC offset their timezone has
+// when the match is built is kept with them, so the window doesn't follow later daylight saving changes
+func NewMatchTimeWindow(start time.Time, stop time.Time, days ...time.Weekday) *MatchTime {
+	start, stop = fixedTimeOfDay(start), fixedTimeOfDay(stop)
+	return &MatchTime{
+		TimeStart: &start,
+		TimeStop:  &stop,
+		Weekdays:  days,
+	}
+}
+
+// MatchTime matches packets that arrive within a time window. TimeStart and TimeStop only use the time of day
+// while DateStart and DateStop limit the match to a range of dates. Times are converted to UTC unless KernelTZ is
+// set in which case the wall clock of the times is used in the timezone of the kernel. TimeStart and TimeStop are
+// converted with the offset of their own timezone, which must be UTC or a fixed offset such as the ones set by
+// NewMatchTimeWindow, and when the conversion moves the window to another day the Weekdays are moved with it. Windows
+// whose start and stop move by a different number of days, or that move MonthDays to another day, can't be expressed
+// in UTC and need KernelTZ. Contiguous treats a TimeStop before TimeStart as a single window running over midnight
+type MatchTime struct {
+	TimeStart        *time.Time     `json:"time_start,omitempty" yaml:"time_start" xml:"time_start"`
+	TimeStop         *time.Time     `json:"time_stop,omitempty" yaml:"time_stop" xml:"time_stop"`
+	Weekdays         []time.Weekday `json:"weekdays,omitempty" yaml:"weekdays" xml:"weekdays"`
+	WeekdaysNegated  bool           `json:"weekdays_negated,omitempty" yaml:"weekdays_negated" xml:"weekdays_negated"`
+	MonthDays        []int          `json:"month_days,omitempty" yaml:"month_days" xml:"month_days"`
+	MonthDaysNegated bool           `json:"month_days_negated,omitempty" yaml:"month_days_negated" xml:"month_days_negated"`
+	DateStart        *time.Time     `json:"date_start,omitempty" yaml:"date_start" xml:"date_start"`
+	DateStop         *time.Time     `json:"date_stop,omitempty" yaml:"date_stop" xml:"date_stop"`
+	KernelTZ         bool           `json:"kernel_tz,omitempty" yaml:"kernel_tz" xml:"kernel_tz"`
+	Contiguous       bool           `json:"contiguous,omitempty" yaml:"contiguous" xml:"contiguous"`
+}
+
+func (m MatchTime) Name() string {
+	return matchTimeName
+}
+
+func (m *MatchTime) SetName(name string) error {
+	return fmt.Errorf("time match doesn't support setting the name")
+}
+
+// Option returns the first option that is set on the match
+func (m MatchTime) Option() string {
+	return firstOption(&m).Option
+}
+
+func (m *MatchTime) SetOption(option string) error {
+	return fmt.Errorf("time match doesn't support setting the option")
+}
+
+// Value returns the value of the first option that is set on the match
+func (m MatchTime) Value() string {
+	return firstOption(&m).Value
+}
+
+func (m *MatchTime) SetValue(value string) error {
+	return fmt.Errorf("time match doesn't support setting the value")
+}
+
+// Negated returns if the weekdays are negated
+func (m MatchTime) Negated() bool {
+	return m.WeekdaysNegated
+}
+
+// SetNegated sets if the weekdays are negated
+func (m *MatchTime) SetNegated(negated bool) {
+	m.WeekdaysNegated = negated
+}
+
+// Options returns the options of the match in the order iptables prints them
+func (m MatchTime) Options() []MatchOption {
+	options := make([]MatchOption, 0)
+	if m.TimeStart != nil {
+		value, _ := m.timeOfDay(*m.TimeStart)
+		options = append(options, MatchOption{Option: "timestart", Value: value})
+	}
+	if m.TimeStop != nil {
+		value, _ := m.timeOfDay(*m.TimeStop)
+		options = append(options, MatchOption{Option: "timestop", Value: value})
+	}
+	if len(m.MonthDays) > 0 {
+		days := make([]string, 0)
+		for _, day := range m.MonthDays {
+			days = append(days, strconv.Itoa(day))
+		}
+		options = append(options, MatchOption{Option: "monthdays", Value: strings.Join(days, ","), Negated: m.MonthDaysNegated})
+	}
+	if len(m.Weekdays) > 0 {
+		// A window that can't be shifted is rejected by Validate
+		shift, _ := m.dayShift()
+		days := make([]string, 0)
+		for _, day := range m.Weekdays {
+			days = append(days, weekdayNames[(day+time.Weekday(shift)+7)%7])
+		}
+		options = append(options, MatchOption{Option: "weekdays", Value: strings.Join(days, ","), Negated: m.WeekdaysNegated})
+	}
+	if m.DateStart != nil {
+		options = append(options, MatchOption{Option: "datestart", Value: m.clock(*m.DateStart).Format(matchTimeDateFormat)})
+	}
+	if m.DateStop != nil {
+		options = append(options, MatchOption{Option: "datestop", Value: m.clock(*m.DateStop).Format(matchTimeDateFormat)})
+	}
+	if m.KernelTZ {
+		options = append(options, MatchOption{Option: "kerneltz"})
+	}
+	if m.Contiguous {
+		options = append(options, MatchOption{Option: "contiguous"})
+	}
+	return options
+}
+
+func (m *MatchTime) Parse(options []MatchOption) (err error) {
+	for _, option := range options {
+		switch option.Option {
+		case "timestart", "timestop":
+			var t time.Time
+			t, err = parseTimeOfDay(option.Value)
+			if option.Option == "timestart" {
+				m.TimeStart = &t
+			} else {
+				m.TimeStop = &t
+			}
+		case "datestart", "datestop":
+			var t time.Time
+			t, err = parseTimeDate(option.Value)
+			if option.Option == "datestart" {
+				m.DateStart = &t
+			} else {
+				m.DateStop = &t
+			}
+		case "weekdays":
+			m.Weekdays, err = parseWeekdays(option.Value)
+			m.WeekdaysNegated = option.Negated
+		case "monthdays":
+			m.MonthDays = make([]int, 0)
+			for _, part := range strings.Split(option.Value, ",") {
+				var day int
+				day, err = strconv.Atoi(part)
+				if err != nil {
+					break
+				}
+				m.MonthDays = append(m.MonthDays, day)
+			}
+			m.MonthDaysNegated = option.Negated
+		case "kerneltz":
+			m.KernelTZ = true
+		case "localtz", "utc":
+			m.KernelTZ = false
+		case "contiguous":
+			m.Contiguous = true
+		default:
+			return fmt.Errorf("unsupported time option --%s", option.Option)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for --%s: %v", option.Option, err)
+		}
+	}
+	return nil
+}
+
+func (m MatchTime) String() string {
+	return matchString(&m)
+}
+
+func (m *MatchTime) Validate(rule Rule) error {
+	if len(m.Options()) == 0 {
+		return fmt.Errorf("time match requires at least one option")
+	}
+	for _, day := range m.Weekdays {
+		if day < time.Sunday || day > time.Saturday {
+			return fmt.Errorf("invalid time match weekday %d", day)
+		}
+	}
+	for _, day := range m.MonthDays {
+		if day < 1 || day > 31 {
+			return fmt.Errorf("invalid time match month day %d. expected 1 to 31", day)
+		}
+	}
+	for _, date := range []*time.Time{m.DateStart, m.DateStop} {
+		if date == nil {
+			continue
+		}
+		if year := m.clock(*date).Year(); year < 1970 || year > 2038 {
+			return fmt.Errorf("time match date %s is outside of 1970 to 2038", date.Format(matchTimeDateFormat))
+		}
+	}
+	if m.DateStart != nil && m.DateStop != nil && m.DateStop.Before(*m.DateStart) {
+		return fmt.Errorf("time match date stop is before date start")
+	}
+	if m.Contiguous && (m.TimeStart == nil || m.TimeStop == nil) {
+		return fmt.Errorf("time match contiguous requires both a time start and time stop")
+	}
+	for _, t := range []*time.Time{m.TimeStart, m.TimeStop} {
+		if t != nil && !m.KernelTZ && !fixedOffset(*t) {
+			return fmt.Errorf("time match time %s is in %s which changes its UTC offset, use NewMatchTimeWindow, UTC times or kernel tz", t.Format("15:04:05"), t.Location())
+		}
+	}
+	shift, err := m.dayShift()
+	if err != nil {
+		return err
+	}
+	if shift != 0 && len(m.MonthDays) > 0 {
+		return fmt.Errorf("time match month days can't be moved to the day the window has in UTC, use UTC times or kernel tz")
+	}
+	return nil
+}
+
+// clock returns the date in the timezone iptables will interpret it in
+func (m MatchTime) clock(t time.Time) time.Time {
+	if m.KernelTZ {
+		return t
+	}
+	return t.UTC()
+}
+
+// timeOfDay returns the time of day passed to iptables for t and the number of days the conversion to UTC moved it
+// by. The offset t carries is used so the value doesn't depend on when the match is rendered
+func (m MatchTime) timeOfDay(t time.Time) (value string, shift int) {
+	seconds := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	if !m.KernelTZ {
+		_, offset := t.Zone()
+		seconds -= offset
+	}
+	for seconds < 0 {
+		seconds += 24 * 3600
+		shift--
+	}
+	for seconds >= 24*3600 {
+		seconds -= 24 * 3600
+		shift++
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60), shift
+}
+
+// dayShift returns the number of days the conversion to UTC moves the window by. An error is returned when the start
+// and stop are moved by a different number of days as the days then can't be moved with them
+func (m MatchTime) dayShift() (int, error) {
+	shifts := make([]int, 0)
+	for _, t := range []*time.Time{m.TimeStart, m.TimeStop} {
+		if t != nil {
+			_, shift := m.timeOfDay(*t)
+			shifts = append(shifts, shift)
+		}
+	}
+	if len(shifts) == 0 {
+		return 0, nil
+	}
+	if len(shifts) == 2 && shifts[0] != shifts[1] && (len(m.Weekdays) > 0 || len(m.MonthDays) > 0) {
+		return 0, fmt.Errorf("time match window crosses midnight in UTC so its days can't be converted, use UTC times or kernel tz")
+	}
+	return shifts[0], nil
+}
+
+// fixedTimeOfDay returns the time of day of t in a zone with the offset the timezone of t has now. A time of day
+// usually carries a date, such as year 0, where the timezone had a different offset
+func fixedTimeOfDay(t time.Time) time.Time {
+	name, offset := time.Now().In(t.Location()).Zone()
+	return time.Date(0, time.January, 1, t.Hour(), t.Minute(), t.Second(), 0, time.FixedZone(name, offset))
+}
+
+// fixedOffset returns true if the timezone of t had the offset of t in both halves of a year, which is the case for
+// UTC and fixed zones but not for zones with daylight saving time or whose offset changed since the date of t
+func fixedOffset(t time.Time) bool {
+	_, offset := t.Zone()
+	for _, month := range []time.Month{time.January, time.July} {
+		if _, o := time.Date(2001, month, 1, 0, 0, 0, 0, t.Location()).Zone(); o != offset {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTimeOfDay parses a hh:mm[:ss] time of day
+func parseTimeOfDay(value string) (time.Time, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return time.Time{}, fmt.Errorf("expected hh:mm[:ss] got %s", value)
+	}
+	limits := []int{23, 59, 59}
+	clock := []int{0, 0, 0}
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 || v > limits[i] {
+			return time.Time{}, fmt.Errorf("expected hh:mm[:ss] got %s", value)
+		}
+		clock[i] = v
+	}
+	return time.Date(0, time.January, 1, clock[0], clock[1], clock[2], 0, time.UTC), nil
+}
+
+// parseTimeDate parses a YYYY[-MM[-DD[Thh[:mm[:ss]]]]] date the way iptables does
+func parseTimeDate(value string) (time.Time, error) {
+	layouts := []string{"2006", "2006-01", "2006-01-02", "2006-01-02T15", "2006-01-02T15:04", matchTimeDateFormat}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected YYYY[-MM[-DD[Thh[:mm[:ss]]]]] got %s", value)
+}
+
+// parseWeekdays parses a comma separated list of day names or numbers where Monday is 1 and Sunday is 7
+func parseWeekdays(value string) ([]time.Weekday, error) {
+	days := make([]time.Weekday, 0)
+	for _, part := range strings.Split(value, ",") {
+		found := false
+		if n, err := strconv.Atoi(part); err == nil && n >= 1 && n <= 7 {
+			days = append(days, weekdayOrder[n-1])
+			continue
+		}
+		for day, name := range weekdayNames {
+			if strings.EqualFold(part, name) || strings.EqualFold(part, day.String()) {
+				days = append(days, day)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown weekday %s", part)
+		}
+	}
+	return days, nil
+}
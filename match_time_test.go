@@ -0,0 +1,50 @@
+package iptables
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchTimeWindow(t *testing.T) {
+	zone := time.FixedZone("UTC+2", 2*3600)
+	start := time.Date(2021, time.March, 1, 1, 0, 0, 0, zone)
+	stop := time.Date(2021, time.March, 1, 1, 45, 0, 0, zone)
+	m := NewMatchTimeWindow(start, stop, time.Monday, time.Friday)
+	if err := m.Validate(Rule{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "--match time --timestart 23:00:00 --timestop 23:45:00 --weekdays Sun,Thu"
+	if got := m.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestMatchTimeChangingOffset(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone database isn't available: %v", err)
+	}
+	start := time.Date(2021, time.July, 1, 9, 0, 0, 0, loc)
+	stop := time.Date(2021, time.July, 1, 17, 0, 0, 0, loc)
+
+	m := &MatchTime{TimeStart: &start, TimeStop: &stop}
+	if err := m.Validate(Rule{}); err == nil {
+		t.Errorf("got no error for times in a timezone with daylight saving time")
+	}
+	m.KernelTZ = true
+	if err := m.Validate(Rule{}); err != nil {
+		t.Errorf("unexpected error with kernel tz: %v", err)
+	}
+
+	// The offset the timezone has when the window is built is kept with the window
+	w := NewMatchTimeWindow(start, stop)
+	if err := w.Validate(Rule{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, offset := time.Now().In(loc).Zone()
+	utc := time.Date(0, time.January, 1, 9, 0, 0, 0, time.UTC).Add(-time.Duration(offset) * time.Second)
+	want := "--match time --timestart " + utc.Format("15:04:05") + " --timestop " + utc.Add(8*time.Hour).Format("15:04:05")
+	if got := w.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package iptables
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	matchU32Name   = "u32"
+	matchU32Option = "u32"
+
+	// matchU32MaxTests is the most tests the kernel accepts in a single u32 match
+	matchU32MaxTests = 10
+)
+
+var (
+	u32LocationRegex = regexp.MustCompile(`^[0-9a-fA-FxX&<>@ ]+$`)
+	u32RangeRegex    = regexp.MustCompile(`^[0-9a-fA-FxX:, ]+$`)
+)
+
+// NewMatchU32 returns a u32 match for the test expression
+func NewMatchU32(test string) *MatchU32 {
+	return &MatchU32{
+		Test: test,
+	}
+}
+
+// MatchU32 matches packets using a u32 test expression such as '0>>22&0x3C@12>>16=53'. Multiple tests can be
+// combined with &&
+type MatchU32 struct {
+	Test        string `json:"test" yaml:"test" xml:"test"`
+	TestNegated bool   `json:"test_negated,omitempty" yaml:"test_negated" xml:"test_negated"`
+}
+
+func (m MatchU32) Name() string {
+	return matchU32Name
+}
+
+func (m *MatchU32) SetName(name string) error {
+	return fmt.Errorf("u32 match doesn't support setting the name")
+}
+
+func (m MatchU32) Option() string {
+	return matchU32Option
+}
+
+func (m *MatchU32) SetOption(option string) error {
+	return fmt.Errorf("u32 match doesn't support setting the option")
+}
+
+func (m MatchU32) Value() string {
+	return m.Test
+}
+
+// SetValue sets the test
+func (m *MatchU32) SetValue(value string) error {
+	m.Test = value
+	return nil
+}
+
+func (m MatchU32) Negated() bool {
+	return m.TestNegated
+}
+
+func (m *MatchU32) SetNegated(negated bool) {
+	m.TestNegated = negated
+}
+
+// Options returns the test quoted the same way iptables prints it
+func (m MatchU32) Options() []MatchOption {
+	return []MatchOption{{Option: matchU32Option, Value: quoteString(m.Test, "\"\\"), Negated: m.TestNegated}}
+}
+
+func (m *MatchU32) Parse(options []MatchOption) error {
+	for _, option := range options {
+		if option.Option != matchU32Option {
+			return fmt.Errorf("unsupported u32 option --%s", option.Option)
+		}
+		m.Test = unquoteArg(option.Value)
+		m.TestNegated = option.Negated
+	}
+	return nil
+}
+
+func (m MatchU32) String() string {
+	return matchString(&m)
+}
+
+// Validate checks the structure of the test, the values themselves are checked by iptables
+func (m *MatchU32) Validate(rule Rule) error {
+	if strings.TrimSpace(m.Test) == "" {
+		return fmt.Errorf("u32 match requires a test")
+	}
+	tests := strings.Split(m.Test, "&&")
+	if len(tests) > matchU32MaxTests {
+		return fmt.Errorf("u32 match supports at most %d tests", matchU32MaxTests)
+	}
+	for _, test := range tests {
+		parts := strings.Split(test, "=")
+		if len(parts) != 2 || !u32LocationRegex.MatchString(parts[0]) || !u32RangeRegex.MatchString(parts[1]) {
+			return fmt.Errorf("invalid u32 match test '%s'. expected <location>=<value>[:<value>][,...]", strings.TrimSpace(test))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+// Package ipset manages ipset sets using the same executor as the iptables package so that large allow and block
+// lists can be maintained as a single set referenced by a rule instead of a rule per address
+package ipset
+
+import (
+	"fmt"
+	"github.com/BGrewell/go-iptables"
+	"strconv"
+	"strings"
+)
+
+// SetType represents the supported set types
+type SetType string
+
+const (
+	SetTypeHashIP     SetType = "hash:ip"
+	SetTypeHashNet    SetType = "hash:net"
+	SetTypeHashIPPort SetType = "hash:ip,port"
+	SetTypeBitmapPort SetType = "bitmap:port"
+)
+
+// Family represents the address family of a hash set
+type Family string
+
+const (
+	FamilyInet  Family = "inet"
+	FamilyInet6 Family = "inet6"
+)
+
+const (
+	maxNameLength = 31
+)
+
+var (
+	setTypes = []SetType{SetTypeHashIP, SetTypeHashNet, SetTypeHashIPPort, SetTypeBitmapPort}
+	// createFlags and addFlags are the options ipset save prints without a value on create and add lines
+	createFlags = map[string]bool{"counters": true, "comment": true, "skbinfo": true, "forceadd": true}
+	addFlags    = map[string]bool{"nomatch": true}
+)
+
+// Set describes an ipset set. Timeout is the default timeout in seconds of the entries in the set, zero means the
+// entries don't expire. Range is required by bitmap:port sets and has the format <from>-<to>
+type Set struct {
+	Name     string  `json:"name" yaml:"name" xml:"name"`
+	Type     SetType `json:"type" yaml:"type" xml:"type"`
+	Family   Family  `json:"family,omitempty" yaml:"family" xml:"family"`
+	Timeout  int     `json:"timeout,omitempty" yaml:"timeout" xml:"timeout"`
+	HashSize int     `json:"hash_size,omitempty" yaml:"hash_size" xml:"hash_size"`
+	MaxElem  int     `json:"max_elem,omitempty" yaml:"max_elem" xml:"max_elem"`
+	Range    string  `json:"range,omitempty" yaml:"range" xml:"range"`
+	Entries  []Entry `json:"entries,omitempty" yaml:"entries" xml:"entries"`
+}
+
+// Entry is a single member of a set. Timeout is the remaining time in seconds before the entry expires, zero means
+// the set default is used when adding and that the entry doesn't expire when listing
+type Entry struct {
+	Value   string `json:"value" yaml:"value" xml:"value"`
+	Timeout int    `json:"timeout,omitempty" yaml:"timeout" xml:"timeout"`
+}
+
+// Validate checks that the set can be created
+func (s Set) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("set requires a name")
+	}
+	if len(s.Name) > maxNameLength {
+		return fmt.Errorf("set name %s is longer than %d characters", s.Name, maxNameLength)
+	}
+
+	valid := false
+	for _, t := range setTypes {
+		if t == s.Type {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("unsupported set type %s", s.Type)
+	}
+
+	if s.Type == SetTypeBitmapPort {
+		if s.Range == "" {
+			return fmt.Errorf("set type %s requires a port range", s.Type)
+		}
+		if s.Family != "" || s.HashSize != 0 {
+			return fmt.Errorf("set type %s doesn't support a family or hash size", s.Type)
+		}
+	} else if s.Range != "" {
+		return fmt.Errorf("set type %s doesn't support a range", s.Type)
+	}
+
+	if s.Family != "" && s.Family != FamilyInet && s.Family != FamilyInet6 {
+		return fmt.Errorf("invalid set family %s", s.Family)
+	}
+
+	if s.Timeout < 0 || s.HashSize < 0 || s.MaxElem < 0 {
+		return fmt.Errorf("set timeout, hash size and max elements can't be negative")
+	}
+
+	return nil
+}
+
+// args returns the create arguments of the set
+func (s Set) args() []string {
+	args := []string{s.Name, string(s.Type)}
+	if s.Family != "" {
+		args = append(args, "family", string(s.Family))
+	}
+	if s.Range != "" {
+		args = append(args, "range", s.Range)
+	}
+	if s.HashSize > 0 {
+		args = append(args, "hashsize", strconv.Itoa(s.HashSize))
+	}
+	if s.MaxElem > 0 {
+		args = append(args, "maxelem", strconv.Itoa(s.MaxElem))
+	}
+	if s.Timeout > 0 {
+		args = append(args, "timeout", strconv.Itoa(s.Timeout))
+	}
+	return args
+}
+
+// Create creates the set along with any entries it contains
+func Create(set *Set) error {
+	return create(set, false)
+}
+
+// EnsureCreated creates the set if a set with the same name doesn't already exist and then adds the entries it
+// contains. The settings of an existing set are not changed
+func EnsureCreated(set *Set) error {
+	return create(set, true)
+}
+
+func create(set *Set, exist bool) error {
+	if err := set.Validate(); err != nil {
+		return err
+	}
+	args := append([]string{"create"}, set.args()...)
+	if exist {
+		args = append(args, "-exist")
+	}
+	if _, err := ipset(args...); err != nil {
+		return err
+	}
+	for _, entry := range set.Entries {
+		if err := Add(set.Name, entry.Value, entry.Timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Destroy destroys the set. The set must not be referenced by any rules
+func Destroy(name string) error {
+	_, err := ipset("destroy", name)
+	return err
+}
+
+// Flush removes all of the entries from the set
+func Flush(name string) error {
+	_, err := ipset("flush", name)
+	return err
+}
+
+// Swap atomically swaps the contents of two sets of the same type. This is used to replace a set that is in use by
+// filling a temporary set and swapping it in
+func Swap(from string, to string) error {
+	_, err := ipset("swap", from, to)
+	return err
+}
+
+// Add adds the entry to the set. Adding an entry that already exists updates its timeout. A timeout of zero uses
+// the default timeout of the set
+func Add(name string, entry string, timeout int) error {
+	args := []string{"add", name, entry}
+	if timeout > 0 {
+		args = append(args, "timeout", strconv.Itoa(timeout))
+	}
+	args = append(args, "-exist")
+	_, err := ipset(args...)
+	return err
+}
+
+// Delete removes the entry from the set
+func Delete(name string, entry string) error {
+	_, err := ipset("del", name, entry)
+	return err
+}
+
+// Test returns true if the entry is a member of the set
+func Test(name string, entry string) (bool, error) {
+	output, err := ipset("test", name, entry)
+	if err != nil {
+		if strings.Contains(output, "is NOT in set") {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// List returns the names of all of the sets
+func List() (names []string, err error) {
+	output, err := ipset("list", "-name")
+	if err != nil {
+		return nil, err
+	}
+	names = make([]string, 0)
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) != "" {
+			names = append(names, strings.TrimSpace(line))
+		}
+	}
+	return names, nil
+}
+
+// Get returns the set along with all of its entries
+func Get(name string) (set *Set, err error) {
+	output, err := ipset("save", name)
+	if err != nil {
+		return nil, err
+	}
+	return parseSave(name, output)
+}
+
+// parseSave parses the create and add lines of ipset save for a single set
+func parseSave(name string, output string) (set *Set, err error) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != name {
+			continue
+		}
+		switch fields[0] {
+		case "create":
+			set = &Set{
+				Name:    name,
+				Type:    SetType(fields[2]),
+				Entries: make([]Entry, 0),
+			}
+			options := saveOptions(fields[3:], createFlags)
+			set.Family = Family(options["family"])
+			set.Range = options["range"]
+			numbers := []struct {
+				key   string
+				value *int
+			}{{"hashsize", &set.HashSize}, {"maxelem", &set.MaxElem}, {"timeout", &set.Timeout}}
+			for _, number := range numbers {
+				if options[number.key] == "" {
+					continue
+				}
+				if *number.value, err = strconv.Atoi(options[number.key]); err != nil {
+					return nil, fmt.Errorf("invalid value for %s: %v", number.key, err)
+				}
+			}
+		case "add":
+			if set == nil {
+				return nil, fmt.Errorf("entry for set %s listed before the set was created", name)
+			}
+			entry := Entry{Value: fields[2]}
+			if timeout := saveOptions(fields[3:], addFlags)["timeout"]; timeout != "" {
+				entry.Timeout, err = strconv.Atoi(timeout)
+				if err != nil {
+					return nil, fmt.Errorf("invalid timeout for entry %s: %v", entry.Value, err)
+				}
+			}
+			set.Entries = append(set.Entries, entry)
+		}
+	}
+	if set == nil {
+		return nil, fmt.Errorf("set %s was not found", name)
+	}
+	return set, nil
+}
+
+// saveOptions returns the options of a line of ipset save. Flags are options without a value, every other option is
+// followed by its value which is quoted when it contains spaces
+func saveOptions(fields []string, flags map[string]bool) map[string]string {
+	options := make(map[string]string)
+	for idx := 0; idx < len(fields); idx++ {
+		key := fields[idx]
+		if flags[key] || idx+1 == len(fields) {
+			options[key] = ""
+			continue
+		}
+		idx++
+		value := fields[idx]
+		for strings.HasPrefix(value, "\"") && (len(value) == 1 || !strings.HasSuffix(value, "\"")) && idx+1 < len(fields) {
+			idx++
+			value += " " + fields[idx]
+		}
+		options[key] = strings.Trim(value, "\"")
+	}
+	return options
+}
+
+// ipset runs the ipset binary with the passed in arguments using the iptables executor
+func ipset(args ...string) (output string, err error) {
+	executor := iptables.GetExecutor()
+	binary, err := executor.LookPath("ipset")
+	if err != nil {
+		return "", err
+	}
+	command := strings.Join(append([]string{binary}, args...), " ")
+	output, err = executor.Execute(command)
+	if err != nil {
+		return output, fmt.Errorf("ipset %s failed: %w: %s", args[0], err, strings.TrimSpace(output))
+	}
+	return output, nil
+}
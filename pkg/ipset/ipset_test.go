@@ -0,0 +1,247 @@
+package ipset
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BGrewell/go-iptables"
+)
+
+// useFakeExecutor installs a FakeExecutor loaded with the records for the duration of the test
+func useFakeExecutor(t *testing.T, records ...iptables.ExecRecord) *iptables.FakeExecutor {
+	t.Helper()
+	previous := iptables.GetExecutor()
+	fake := iptables.NewFakeExecutor(records...)
+	iptables.SetExecutor(fake)
+	t.Cleanup(func() {
+		iptables.SetExecutor(previous)
+	})
+	return fake
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     Set
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "hash with entries",
+			set: Set{
+				Name:     "blocked",
+				Type:     SetTypeHashNet,
+				Family:   FamilyInet,
+				HashSize: 1024,
+				Timeout:  300,
+				Entries:  []Entry{{Value: "10.0.0.0/8"}, {Value: "192.0.2.1", Timeout: 60}},
+			},
+			want: []string{
+				"ipset create blocked hash:net family inet hashsize 1024 timeout 300",
+				"ipset add blocked 10.0.0.0/8 -exist",
+				"ipset add blocked 192.0.2.1 timeout 60 -exist",
+			},
+		},
+		{
+			name: "bitmap",
+			set:  Set{Name: "ports", Type: SetTypeBitmapPort, Range: "1-1024"},
+			want: []string{"ipset create ports bitmap:port range 1-1024"},
+		},
+		{
+			name:    "bitmap without range",
+			set:     Set{Name: "ports", Type: SetTypeBitmapPort},
+			wantErr: true,
+		},
+		{
+			name:    "range on a hash set",
+			set:     Set{Name: "hosts", Type: SetTypeHashIP, Range: "1-1024"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type",
+			set:     Set{Name: "hosts", Type: "list:set"},
+			wantErr: true,
+		},
+		{
+			name:    "name too long",
+			set:     Set{Name: "a-set-name-that-is-much-too-long", Type: SetTypeHashIP},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeExecutor(t)
+			err := Create(&tt.set)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(fake.Commands()) != 0 {
+					t.Errorf("invalid set ran %v", fake.Commands())
+				}
+				return
+			}
+			if got := fake.Commands(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got commands %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureCreated(t *testing.T) {
+	fake := useFakeExecutor(t)
+	set := &Set{Name: "allowed", Type: SetTypeHashIP, Entries: []Entry{{Value: "192.0.2.1"}}}
+	if err := EnsureCreated(set); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"ipset create allowed hash:ip -exist",
+		"ipset add allowed 192.0.2.1 -exist",
+	}
+	if got := fake.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	fake := useFakeExecutor(t, iptables.ExecRecord{
+		Command: "ipset add missing 192.0.2.1 -exist",
+		Output:  "ipset v7.15: The set with the given name does not exist\n",
+		Error:   "exit status 1",
+	})
+	if err := Add("allowed", "192.0.2.1", 30); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"ipset add allowed 192.0.2.1 timeout 30 -exist"}
+	if got := fake.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+
+	err := Add("missing", "192.0.2.1", 0)
+	message := "ipset add failed: exit status 1: ipset v7.15: The set with the given name does not exist"
+	if err == nil || err.Error() != message {
+		t.Errorf("got error %v, want %s", err, message)
+	}
+}
+
+func TestTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		err     string
+		want    bool
+		wantErr bool
+	}{
+		{name: "member", output: "192.0.2.1 is in set allowed.", want: true},
+		{name: "not a member", output: "192.0.2.1 is NOT in set allowed.", err: "exit status 1"},
+		{name: "failure", output: "The set with the given name does not exist", err: "exit status 1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeExecutor(t, iptables.ExecRecord{Command: "ipset test allowed 192.0.2.1", Output: tt.output, Error: tt.err})
+			got, err := Test("allowed", "192.0.2.1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	output := "create allowed hash:ip family inet hashsize 1024 maxelem 65536 timeout 300\n" +
+		"add allowed 192.0.2.1 timeout 120\n" +
+		"add allowed 192.0.2.2 timeout 0\n"
+	fake := useFakeExecutor(t, iptables.ExecRecord{Command: "ipset save allowed", Output: output})
+	got, err := Get("allowed")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &Set{
+		Name:     "allowed",
+		Type:     SetTypeHashIP,
+		Family:   FamilyInet,
+		HashSize: 1024,
+		MaxElem:  65536,
+		Timeout:  300,
+		Entries:  []Entry{{Value: "192.0.2.1", Timeout: 120}, {Value: "192.0.2.2"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if commands := fake.Commands(); !reflect.DeepEqual(commands, []string{"ipset save allowed"}) {
+		t.Errorf("got commands %q", commands)
+	}
+
+	if _, err := Get("missing"); err == nil {
+		t.Errorf("got no error for a set that wasn't listed")
+	}
+}
+
+func TestParseSave(t *testing.T) {
+	tests := []struct {
+		name   string
+		set    string
+		output string
+		want   *Set
+	}{
+		{
+			name: "flags",
+			set:  "blocked",
+			output: "create blocked hash:ip family inet hashsize 1024 maxelem 65536 timeout 300 counters comment skbinfo bucketsize 12 initval 0x5cb8a3f1\n" +
+				"add blocked 192.0.2.1 timeout 120 packets 4 bytes 240 comment \"scanner from the office\" skbmark 0x1\n" +
+				"add blocked 192.0.2.2 timeout 0 packets 0 bytes 0 comment \"manual\"\n",
+			want: &Set{
+				Name:     "blocked",
+				Type:     SetTypeHashIP,
+				Family:   FamilyInet,
+				HashSize: 1024,
+				MaxElem:  65536,
+				Timeout:  300,
+				Entries:  []Entry{{Value: "192.0.2.1", Timeout: 120}, {Value: "192.0.2.2"}},
+			},
+		},
+		{
+			name: "nomatch entry",
+			set:  "nets",
+			output: "create nets hash:net family inet6 hashsize 1024 maxelem 65536 forceadd timeout 60\n" +
+				"add nets 2001:db8::/32 nomatch timeout 30\n",
+			want: &Set{
+				Name:     "nets",
+				Type:     SetTypeHashNet,
+				Family:   FamilyInet6,
+				HashSize: 1024,
+				MaxElem:  65536,
+				Timeout:  60,
+				Entries:  []Entry{{Value: "2001:db8::/32", Timeout: 30}},
+			},
+		},
+		{
+			name: "other sets",
+			set:  "ports",
+			output: "create hosts hash:ip family inet hashsize 1024 maxelem 65536\n" +
+				"add hosts 192.0.2.1\n" +
+				"create ports bitmap:port range 1-1024 counters\n" +
+				"add ports 22 packets 10 bytes 600\n",
+			want: &Set{
+				Name:    "ports",
+				Type:    SetTypeBitmapPort,
+				Range:   "1-1024",
+				Entries: []Entry{{Value: "22"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSave(tt.set, tt.output)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Package nfqueue receives the packets sent to userspace by an NFQUEUE rule and returns a verdict for each of them
+// from a Go callback. It talks to the kernel's nfnetlink_queue subsystem directly over a netlink socket, so it needs
+// CAP_NET_ADMIN in the network namespace of the rule but no other libraries. An unprivileged user and network
+// namespace, for example unshare -Urn, is enough to run it
+package nfqueue
+
+import (
+	"fmt"
+)
+
+// Verdict is the decision returned to the kernel for a queued packet
+type Verdict uint32
+
+const (
+	VerdictDrop   Verdict = 0
+	VerdictAccept Verdict = 1
+	VerdictRepeat Verdict = 4
+)
+
+// Packet is a packet received from the queue. Payload holds the network header onwards, up to the copy range of
+// the queue. InDev and OutDev are interface indexes, zero when the packet has no input or output device
+type Packet struct {
+	Id         uint32
+	HwProtocol uint16
+	Hook       uint8
+	Mark       uint32
+	InDev      uint32
+	OutDev     uint32
+	Payload    []byte
+}
+
+// HandlerFunc decides the verdict of a packet. It is called for each packet in the order they were queued and
+// the next packet is not read until it returns
+type HandlerFunc func(packet *Packet) Verdict
+
+// Config describes the queue to bind. CopyRange is the number of bytes of each packet that are copied to
+// userspace, zero copies up to 65535 bytes. MaxLen is the number of packets the kernel holds while waiting for a
+// verdict, zero leaves the kernel default. FailOpen accepts packets instead of dropping them when the queue is full
+type Config struct {
+	QueueNum  uint16 `json:"queue_num" yaml:"queue_num" xml:"queue_num"`
+	CopyRange uint32 `json:"copy_range,omitempty" yaml:"copy_range" xml:"copy_range"`
+	MaxLen    uint32 `json:"max_len,omitempty" yaml:"max_len" xml:"max_len"`
+	FailOpen  bool   `json:"fail_open,omitempty" yaml:"fail_open" xml:"fail_open"`
+}
+
+// Validate checks that the queue can be bound
+func (c Config) Validate() error {
+	if c.CopyRange > 0xffff {
+		return fmt.Errorf("copy range %d is larger than the maximum of 65535", c.CopyRange)
+	}
+	return nil
+}
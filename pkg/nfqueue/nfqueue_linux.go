@@ -0,0 +1,376 @@
+//go:build linux
+// +build linux
+
+package nfqueue
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	netlinkNetfilter = 12
+
+	nfnlSubsysQueue = 3
+	nfnetlinkV0     = 0
+
+	nfqnlMsgPacket  = 0
+	nfqnlMsgVerdict = 1
+	nfqnlMsgConfig  = 2
+
+	nfqnlCfgCmdBind   = 1
+	nfqnlCfgCmdUnbind = 2
+
+	nfqnlCopyPacket = 2
+
+	nfqaCfgCmd         = 1
+	nfqaCfgParams      = 2
+	nfqaCfgQueueMaxLen = 3
+	nfqaCfgMask        = 4
+	nfqaCfgFlags       = 5
+	nfqaCfgFFailOpen   = 1
+
+	nfqaPacketHdr  = 1
+	nfqaVerdictHdr = 2
+	nfqaMark       = 3
+	nfqaIfIndexIn  = 5
+	nfqaIfIndexOut = 6
+	nfqaPayload    = 10
+
+	nlaTypeMask = 0x3fff
+	nlaHdrLen   = 4
+	nfGenMsgLen = 4
+
+	receiveBufferSize = 0x10000 + 4096
+	receiveTimeout    = 500 * time.Millisecond
+)
+
+// nativeEndian is the byte order of the host. Netlink headers and attribute headers are in host byte order, only
+// the fields the kernel declares as __be are big endian
+var nativeEndian = func() binary.ByteOrder {
+	value := uint16(1)
+	if *(*byte)(unsafe.Pointer(&value)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// Queue is a netlink socket bound to an nfnetlink_queue queue. Packets that arrive while a config message waits for
+// its ack are kept in pending until Run handles them
+type Queue struct {
+	config  Config
+	fd      int
+	seq     uint32
+	closed  int32
+	running bool
+	done    chan struct{}
+	pending []syscall.NetlinkMessage
+	lock    sync.Mutex
+}
+
+// Open binds the queue described by config, packets are not received until Run is called
+func Open(config Config) (queue *Queue, err error) {
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, netlinkNetfilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netlink socket: %v", err)
+	}
+	if err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind netlink socket: %v", err)
+	}
+	tv := syscall.NsecToTimeval(receiveTimeout.Nanoseconds())
+	if err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to set netlink socket timeout: %v", err)
+	}
+
+	queue = &Queue{config: config, fd: fd}
+	if err = queue.bind(); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return queue, nil
+}
+
+// Run reads packets from the queue and returns the verdict of the handler for each of them until Close is called
+func (q *Queue) Run(handler HandlerFunc) error {
+	q.lock.Lock()
+	if q.running || atomic.LoadInt32(&q.closed) != 0 {
+		q.lock.Unlock()
+		return fmt.Errorf("queue %d is already running or closed", q.config.QueueNum)
+	}
+	q.running = true
+	q.done = make(chan struct{})
+	q.lock.Unlock()
+	defer close(q.done)
+
+	buf := make([]byte, receiveBufferSize)
+	for atomic.LoadInt32(&q.closed) == 0 {
+		q.lock.Lock()
+		pending := q.pending
+		q.pending = nil
+		q.lock.Unlock()
+		if err := q.handle(handler, pending); err != nil {
+			return err
+		}
+
+		n, _, err := syscall.Recvfrom(q.fd, buf, 0)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			continue
+		} else if err == syscall.ENOBUFS {
+			// The socket buffer overflowed and packets were lost, the kernel drops or accepts them on its own
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to read from queue %d: %v", q.config.QueueNum, err)
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return fmt.Errorf("failed to parse message from queue %d: %v", q.config.QueueNum, err)
+		}
+		if err = q.handle(handler, msgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle returns the verdict of the handler for each packet message in msgs
+func (q *Queue) handle(handler HandlerFunc, msgs []syscall.NetlinkMessage) error {
+	for _, msg := range msgs {
+		if msg.Header.Type != nfqnlType(nfqnlMsgPacket) {
+			continue
+		}
+		packet, err := parsePacket(msg.Data)
+		if err != nil {
+			return fmt.Errorf("failed to parse packet from queue %d: %v", q.config.QueueNum, err)
+		}
+		if err = q.SetVerdict(packet.Id, handler(packet)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetVerdict returns the verdict for the packet with the id to the kernel
+func (q *Queue) SetVerdict(id uint32, verdict Verdict) error {
+	hdr := make([]byte, 8)
+	binary.BigEndian.PutUint32(hdr[0:4], uint32(verdict))
+	binary.BigEndian.PutUint32(hdr[4:8], id)
+	msg := q.message(nfqnlMsgVerdict, 0, attribute(nfqaVerdictHdr, hdr))
+	if err := syscall.Sendto(q.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return fmt.Errorf("failed to set verdict of packet %d: %v", id, err)
+	}
+	return nil
+}
+
+// Close stops Run, unbinds the queue and closes the socket. When Run is active it returns once its current read
+// times out
+func (q *Queue) Close() error {
+	if !atomic.CompareAndSwapInt32(&q.closed, 0, 1) {
+		return nil
+	}
+	q.lock.Lock()
+	running, done := q.running, q.done
+	q.lock.Unlock()
+	if running {
+		<-done
+	}
+
+	err := q.command(nfqnlCfgCmdUnbind)
+	if cerr := syscall.Close(q.fd); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// bind binds the socket to the queue and configures the copy mode, the queue length and the flags
+func (q *Queue) bind() error {
+	if err := q.command(nfqnlCfgCmdBind); err != nil {
+		return err
+	}
+
+	copyRange := q.config.CopyRange
+	if copyRange == 0 {
+		copyRange = 0xffff
+	}
+	params := make([]byte, 5)
+	binary.BigEndian.PutUint32(params[0:4], copyRange)
+	params[4] = nfqnlCopyPacket
+	if err := q.configure(attribute(nfqaCfgParams, params)); err != nil {
+		return fmt.Errorf("failed to set the copy mode of queue %d: %v", q.config.QueueNum, err)
+	}
+
+	if q.config.MaxLen != 0 {
+		if err := q.configure(attribute(nfqaCfgQueueMaxLen, be32(q.config.MaxLen))); err != nil {
+			return fmt.Errorf("failed to set the length of queue %d: %v", q.config.QueueNum, err)
+		}
+	}
+
+	if q.config.FailOpen {
+		attrs := append(attribute(nfqaCfgFlags, be32(nfqaCfgFFailOpen)), attribute(nfqaCfgMask, be32(nfqaCfgFFailOpen))...)
+		if err := q.configure(attrs); err != nil {
+			return fmt.Errorf("failed to set fail open on queue %d: %v", q.config.QueueNum, err)
+		}
+	}
+	return nil
+}
+
+// command sends a bind or unbind command for the queue
+func (q *Queue) command(cmd uint8) error {
+	// struct nfqnl_msg_config_cmd { __u8 command; __u8 _pad; __be16 pf; }
+	if err := q.configure(attribute(nfqaCfgCmd, []byte{cmd, 0, 0, 0})); err != nil {
+		action := "bind"
+		if cmd == nfqnlCfgCmdUnbind {
+			action = "unbind"
+		}
+		return fmt.Errorf("failed to %s queue %d: %v", action, q.config.QueueNum, err)
+	}
+	return nil
+}
+
+// configure sends a config message and waits for the kernel to acknowledge it
+func (q *Queue) configure(attrs []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	msg := q.message(nfqnlMsgConfig, syscall.NLM_F_ACK, attrs)
+	if err := syscall.Sendto(q.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+	return q.waitAck(nativeEndian.Uint32(msg[8:12]))
+}
+
+// waitAck reads from the socket until the ack for the sequence number arrives. Packets read in the meantime are
+// added to pending so Run still returns a verdict for them. The lock must be held
+func (q *Queue) waitAck(seq uint32) error {
+	buf := make([]byte, receiveBufferSize)
+	for {
+		n, _, err := syscall.Recvfrom(q.fd, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Type == nfqnlType(nfqnlMsgPacket) {
+				// The data points into buf which is reused by the next read
+				m.Data = append([]byte(nil), m.Data...)
+				q.pending = append(q.pending, m)
+				continue
+			}
+			if m.Header.Type != syscall.NLMSG_ERROR || m.Header.Seq != seq {
+				continue
+			}
+			if len(m.Data) < 4 {
+				return fmt.Errorf("truncated netlink ack")
+			}
+			if errno := int32(nativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+				return syscall.Errno(-errno)
+			}
+			return nil
+		}
+	}
+}
+
+// message builds a netlink message for the queue subsystem with the nfgenmsg header followed by the attributes
+func (q *Queue) message(msgType uint16, flags uint16, attrs []byte) []byte {
+	length := syscall.NLMSG_HDRLEN + nfGenMsgLen + len(attrs)
+	msg := make([]byte, length)
+	nativeEndian.PutUint32(msg[0:4], uint32(length))
+	nativeEndian.PutUint16(msg[4:6], nfqnlType(msgType))
+	nativeEndian.PutUint16(msg[6:8], syscall.NLM_F_REQUEST|flags)
+	nativeEndian.PutUint32(msg[8:12], atomic.AddUint32(&q.seq, 1))
+	// struct nfgenmsg { __u8 nfgen_family; __u8 version; __be16 res_id; }
+	msg[16] = syscall.AF_UNSPEC
+	msg[17] = nfnetlinkV0
+	binary.BigEndian.PutUint16(msg[18:20], q.config.QueueNum)
+	copy(msg[20:], attrs)
+	return msg
+}
+
+// parsePacket reads the attributes of a packet message
+func parsePacket(data []byte) (*Packet, error) {
+	if len(data) < nfGenMsgLen {
+		return nil, fmt.Errorf("truncated packet message")
+	}
+	packet := &Packet{}
+	found := false
+	for b := data[nfGenMsgLen:]; len(b) >= nlaHdrLen; {
+		length := int(nativeEndian.Uint16(b[0:2]))
+		if length < nlaHdrLen || length > len(b) {
+			return nil, fmt.Errorf("invalid attribute length %d", length)
+		}
+		value := b[nlaHdrLen:length]
+		switch nativeEndian.Uint16(b[2:4]) & nlaTypeMask {
+		case nfqaPacketHdr:
+			// struct nfqnl_msg_packet_hdr { __be32 packet_id; __be16 hw_protocol; __u8 hook; }
+			if len(value) < 7 {
+				return nil, fmt.Errorf("truncated packet header")
+			}
+			packet.Id = binary.BigEndian.Uint32(value[0:4])
+			packet.HwProtocol = binary.BigEndian.Uint16(value[4:6])
+			packet.Hook = value[6]
+			found = true
+		case nfqaMark:
+			packet.Mark = beUint32(value)
+		case nfqaIfIndexIn:
+			packet.InDev = beUint32(value)
+		case nfqaIfIndexOut:
+			packet.OutDev = beUint32(value)
+		case nfqaPayload:
+			packet.Payload = append([]byte(nil), value...)
+		}
+		aligned := nlaAlign(length)
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+	if !found {
+		return nil, fmt.Errorf("packet message has no packet header")
+	}
+	return packet, nil
+}
+
+// attribute encodes a netlink attribute padded to the attribute alignment
+func attribute(attrType uint16, value []byte) []byte {
+	length := nlaHdrLen + len(value)
+	attr := make([]byte, nlaAlign(length))
+	nativeEndian.PutUint16(attr[0:2], uint16(length))
+	nativeEndian.PutUint16(attr[2:4], attrType)
+	copy(attr[nlaHdrLen:], value)
+	return attr
+}
+
+func nfqnlType(msgType uint16) uint16 {
+	return nfnlSubsysQueue<<8 | msgType
+}
+
+func nlaAlign(length int) int {
+	return (length + syscall.NLA_ALIGNTO - 1) &^ (syscall.NLA_ALIGNTO - 1)
+}
+
+func be32(value uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, value)
+	return b
+}
+
+func beUint32(value []byte) uint32 {
+	if len(value) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(value)
+}
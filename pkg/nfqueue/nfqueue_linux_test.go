@@ -0,0 +1,234 @@
+//go:build linux
+// +build linux
+
+package nfqueue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// namespaceEnv is set when the test binary has been started again inside a user and network namespace
+const namespaceEnv = "NFQUEUE_TEST_NAMESPACE"
+
+func TestMessage(t *testing.T) {
+	q := &Queue{config: Config{QueueNum: 513}}
+	attrs := attribute(nfqaCfgCmd, []byte{nfqnlCfgCmdBind, 0, 0, 0})
+	msgs, err := syscall.ParseNetlinkMessage(q.message(nfqnlMsgConfig, syscall.NLM_F_ACK, attrs))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages, want 1", len(msgs))
+	}
+	header := msgs[0].Header
+	if header.Type != nfqnlType(nfqnlMsgConfig) {
+		t.Errorf("got type %#x, want %#x", header.Type, nfqnlType(nfqnlMsgConfig))
+	}
+	if header.Flags != syscall.NLM_F_REQUEST|syscall.NLM_F_ACK {
+		t.Errorf("got flags %#x, want %#x", header.Flags, syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	}
+	if header.Seq != 1 {
+		t.Errorf("got sequence %d, want 1", header.Seq)
+	}
+	data := msgs[0].Data
+	if queueNum := binary.BigEndian.Uint16(data[2:4]); queueNum != 513 {
+		t.Errorf("got queue %d, want 513", queueNum)
+	}
+	if !bytes.Equal(data[nfGenMsgLen:], attrs) {
+		t.Errorf("got attributes %x, want %x", data[nfGenMsgLen:], attrs)
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	hdr := []byte{0, 0, 0, 42, 0x08, 0x00, 3}
+	payload := []byte{0x45, 0, 0, 20, 1}
+	data := []byte{syscall.AF_INET, nfnetlinkV0, 0, 7}
+	data = append(data, attribute(nfqaPacketHdr, hdr)...)
+	data = append(data, attribute(nfqaMark, be32(0x10))...)
+	data = append(data, attribute(nfqaIfIndexOut, be32(1))...)
+	data = append(data, attribute(nfqaPayload, payload)...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *Packet
+		wantErr bool
+	}{
+		{
+			name: "packet",
+			data: data,
+			want: &Packet{Id: 42, HwProtocol: 0x0800, Hook: 3, Mark: 0x10, OutDev: 1, Payload: payload},
+		},
+		{
+			name:    "no packet header",
+			data:    append([]byte{syscall.AF_INET, nfnetlinkV0, 0, 7}, attribute(nfqaMark, be32(0x10))...),
+			wantErr: true,
+		},
+		{
+			name:    "truncated attribute",
+			data:    data[:nfGenMsgLen+6],
+			wantErr: true,
+		},
+		{
+			name:    "truncated message",
+			data:    data[:2],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := parsePacket(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", packet)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if packet.Id != tt.want.Id || packet.HwProtocol != tt.want.HwProtocol || packet.Hook != tt.want.Hook ||
+				packet.Mark != tt.want.Mark || packet.InDev != tt.want.InDev || packet.OutDev != tt.want.OutDev ||
+				!bytes.Equal(packet.Payload, tt.want.Payload) {
+				t.Errorf("got %+v, want %+v", packet, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitAck(t *testing.T) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		t.Fatalf("failed to create a socket pair: %v", err)
+	}
+	defer syscall.Close(fds[0])
+	defer syscall.Close(fds[1])
+
+	q := &Queue{config: Config{QueueNum: 7}, fd: fds[0], seq: 40}
+	hdr := []byte{0, 0, 0, 42, 0x08, 0x00, 3}
+	packet := q.message(nfqnlMsgPacket, 0, attribute(nfqaPacketHdr, hdr))
+	config := q.message(nfqnlMsgConfig, syscall.NLM_F_ACK, nil)
+	ack := make([]byte, syscall.NLMSG_HDRLEN+4+syscall.NLMSG_HDRLEN)
+	nativeEndian.PutUint32(ack[0:4], uint32(len(ack)))
+	nativeEndian.PutUint16(ack[4:6], syscall.NLMSG_ERROR)
+	nativeEndian.PutUint32(ack[8:12], 42)
+	copy(ack[20:], config[:syscall.NLMSG_HDRLEN])
+
+	// The packet is read on its own before the ack arrives
+	for _, msg := range [][]byte{packet, ack} {
+		if err = syscall.Sendto(fds[1], msg, 0, nil); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+	}
+	if err = q.waitAck(42); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(q.pending) != 1 {
+		t.Fatalf("got %d pending messages, want the packet", len(q.pending))
+	}
+	p, err := parsePacket(q.pending[0].Data)
+	if err != nil {
+		t.Fatalf("failed to parse the pending packet: %v", err)
+	}
+	if p.Id != 42 {
+		t.Errorf("got pending packet %d, want 42", p.Id)
+	}
+}
+
+// TestVerdict binds a queue in a new user and network namespace, queues udp packets sent over the loopback
+// interface and checks that only the accepted packet is delivered
+func TestVerdict(t *testing.T) {
+	if os.Getenv(namespaceEnv) == "" {
+		if _, err := exec.LookPath("unshare"); err != nil {
+			t.Skip("unshare is not available")
+		}
+		if err := exec.Command("unshare", "-Urn", "true").Run(); err != nil {
+			t.Skipf("can't create a user and network namespace: %v", err)
+		}
+		cmd := exec.Command("unshare", "-Urn", os.Args[0], "-test.run", "^TestVerdict$", "-test.v")
+		cmd.Env = append(os.Environ(), namespaceEnv+"=1")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("test failed in the namespace: %v\n%s", err, output)
+		}
+		if strings.Contains(string(output), "--- SKIP") {
+			t.Skipf("test skipped in the namespace:\n%s", output)
+		}
+		return
+	}
+
+	if output, err := exec.Command("ip", "link", "set", "lo", "up").CombinedOutput(); err != nil {
+		t.Skipf("can't bring up the loopback interface: %v: %s", err, output)
+	}
+
+	queue, err := Open(Config{QueueNum: 7})
+	if err != nil {
+		t.Fatalf("failed to open the queue: %v", err)
+	}
+	defer queue.Close()
+
+	if _, err = exec.LookPath("iptables"); err != nil {
+		t.Skip("iptables is not available to add the NFQUEUE rule")
+	}
+	rule := []string{"OUTPUT", "-o", "lo", "-p", "udp", "--dport", "9999", "-j", "NFQUEUE", "--queue-num", "7"}
+	if output, err := exec.Command("iptables", append([]string{"-A"}, rule...)...).CombinedOutput(); err != nil {
+		t.Skipf("can't add the NFQUEUE rule: %v: %s", err, output)
+	}
+	defer exec.Command("iptables", append([]string{"-D"}, rule...)...).Run()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9999})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	queued := make(chan []byte, 2)
+	go queue.Run(func(packet *Packet) Verdict {
+		queued <- packet.Payload
+		if bytes.HasSuffix(packet.Payload, []byte("drop")) {
+			return VerdictDrop
+		}
+		return VerdictAccept
+	})
+
+	sender, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9999})
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer sender.Close()
+	for _, msg := range []string{"drop", "accept"} {
+		if _, err = sender.Write([]byte(msg)); err != nil {
+			t.Fatalf("failed to send %s: %v", msg, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-queued:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d queued packets, want 2", i)
+		}
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to receive the accepted packet: %v", err)
+	}
+	if string(buf[:n]) != "accept" {
+		t.Errorf("got %q, want the accepted packet", buf[:n])
+	}
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, err = conn.Read(buf); err == nil {
+		t.Errorf("got %q after the accepted packet, the dropped packet was delivered", buf[:n])
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !linux
+// +build !linux
+
+package nfqueue
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Queue is a netlink socket bound to an nfnetlink_queue queue, which only exists on linux
+type Queue struct{}
+
+// Open always fails, nfnetlink_queue is only available on linux
+func Open(config Config) (queue *Queue, err error) {
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+	return nil, unsupported()
+}
+
+func (q *Queue) Run(handler HandlerFunc) error {
+	return unsupported()
+}
+
+func (q *Queue) SetVerdict(id uint32, verdict Verdict) error {
+	return unsupported()
+}
+
+func (q *Queue) Close() error {
+	return nil
+}
+
+func unsupported() error {
+	return fmt.Errorf("nfqueue is not supported on %s", runtime.GOOS)
+}
@@ -9,7 +9,17 @@ const (
 	ProtocolIP      Protocol = "ip"
 	ProtocolIPv6    Protocol = "ipv6"
 	ProtocolICMP    Protocol = "icmp"
+	ProtocolICMPv6  Protocol = "ipv6-icmp"
 	ProtocolTCP     Protocol = "tcp"
 	ProtocolUDP     Protocol = "udp"
 	ProtocolSCTP    Protocol = "sctp"
 )
+
+// IsICMPv6Protocol returns true for any of the names ip6tables accepts for the ICMPv6 protocol
+func IsICMPv6Protocol(protocol Protocol) bool {
+	switch protocol {
+	case ProtocolICMPv6, "icmpv6", "icmp6", "58":
+		return true
+	}
+	return false
+}
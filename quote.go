@@ -0,0 +1,101 @@
+package iptables
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// saveStringChars are the characters iptables prints without quotes in string options such as comments
+	saveStringChars = "_-0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// argSeparators are the characters ip(6)tables-restore splits arguments on
+	argSeparators = " \t\r\n"
+)
+
+// splitArgs splits a command line into arguments the same way ip(6)tables-restore does. Double quotes group
+// words into a single argument and a backslash escapes the next character. The quotes and escapes are kept so each
+// argument can be written out again unchanged, use unquoteArg to get the value of an argument
+func splitArgs(line string) (args []string, err error) {
+	args = make([]string, 0)
+	var current strings.Builder
+	inArg, quoted, escaped := false, false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && strings.IndexByte(argSeparators, c) != -1:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+			continue
+		}
+		current.WriteByte(c)
+		inArg = true
+	}
+	if quoted || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in: %s", line)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// unquoteArg returns the value of an argument returned by splitArgs with the quotes and escapes removed
+func unquoteArg(arg string) string {
+	var value strings.Builder
+	escaped := false
+	for i := 0; i < len(arg); i++ {
+		c := arg[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+			continue
+		case c == '"':
+			continue
+		}
+		value.WriteByte(c)
+	}
+	return value.String()
+}
+
+// quoteArg returns the value as a single argument, it is only quoted when splitArgs would otherwise split or
+// change it
+func quoteArg(value string) string {
+	if value != "" && !strings.ContainsAny(value, argSeparators+"\"\\'") {
+		return value
+	}
+	return quoteString(value, "\"\\'")
+}
+
+// saveString returns the value quoted the same way iptables prints string options such as comments. Anything
+// other than letters, digits, '_' and '-' is quoted and quotes and backslashes are escaped
+func saveString(value string) string {
+	if value != "" && strings.Trim(value, saveStringChars) == "" {
+		return value
+	}
+	return quoteString(value, "\"\\'")
+}
+
+// quoteString surrounds the value with double quotes escaping each of the characters in escape with a backslash
+func quoteString(value string, escape string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		if strings.IndexByte(escape, value[i]) != -1 {
+			quoted.WriteByte('\\')
+		}
+		quoted.WriteByte(value[i])
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
@@ -0,0 +1,111 @@
+package iptables
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{line: "-A INPUT -j ACCEPT", want: []string{"-A", "INPUT", "-j", "ACCEPT"}},
+		{line: "  -A\tINPUT \r\n-j  ACCEPT ", want: []string{"-A", "INPUT", "-j", "ACCEPT"}},
+		{line: `--comment "allow ssh" -j ACCEPT`, want: []string{"--comment", `"allow ssh"`, "-j", "ACCEPT"}},
+		{line: `--comment "say \"hi\""`, want: []string{"--comment", `"say \"hi\""`}},
+		{line: `--comment a\ b`, want: []string{"--comment", `a\ b`}},
+		{line: `--comment ""`, want: []string{"--comment", `""`}},
+		{line: `--log-prefix "dropped: "x`, want: []string{"--log-prefix", `"dropped: "x`}},
+		{line: "", want: []string{}},
+		{line: `--comment "open`, wantErr: true},
+		{line: `--comment open\`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitArgs(%q) = %q, expected an error", tt.line, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitArgs(%q) unexpected error: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestUnquoteArg(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{arg: "ACCEPT", want: "ACCEPT"},
+		{arg: `"allow ssh"`, want: "allow ssh"},
+		{arg: `"say \"hi\""`, want: `say "hi"`},
+		{arg: `a\ b`, want: "a b"},
+		{arg: `"back\\slash"`, want: `back\slash`},
+		{arg: `""`, want: ""},
+	}
+	for _, tt := range tests {
+		if got := unquoteArg(tt.arg); got != tt.want {
+			t.Errorf("unquoteArg(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "user.slice", want: "user.slice"},
+		{value: "10.0.0.1-10.0.0.9", want: "10.0.0.1-10.0.0.9"},
+		{value: "GET /", want: `"GET /"`},
+		{value: `say "hi"`, want: `"say \"hi\""`},
+		{value: `it's`, want: `"it\'s"`},
+		{value: `back\slash`, want: `"back\\slash"`},
+		{value: "", want: `""`},
+	}
+	for _, tt := range tests {
+		got := quoteArg(tt.value)
+		if got != tt.want {
+			t.Errorf("quoteArg(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+		// The quoted value must come back from splitArgs as a single argument with the same value
+		args, err := splitArgs(got)
+		if err != nil || len(args) != 1 || unquoteArg(args[0]) != tt.value {
+			t.Errorf("splitArgs(%q) = %q, %v, want the single argument %q", got, args, err, tt.value)
+		}
+	}
+}
+
+func TestSaveString(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "ssh", want: "ssh"},
+		{value: "allow_ssh-22", want: "allow_ssh-22"},
+		{value: "id:abc", want: `"id:abc"`},
+		{value: "user.slice", want: `"user.slice"`},
+		{value: "allow ssh", want: `"allow ssh"`},
+		{value: `say "hi"`, want: `"say \"hi\""`},
+		{value: "", want: `""`},
+	}
+	for _, tt := range tests {
+		got := saveString(tt.value)
+		if got != tt.want {
+			t.Errorf("saveString(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+		args, err := splitArgs(got)
+		if err != nil || len(args) != 1 || unquoteArg(args[0]) != tt.value {
+			t.Errorf("splitArgs(%q) = %q, %v, want the single argument %q", got, args, err, tt.value)
+		}
+	}
+}
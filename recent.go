@@ -0,0 +1,114 @@
+package iptables
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	recentProcPath = "/proc/net/xt_recent"
+)
+
+// RecentEntry is an address held in a recent list. LastSeen and Hits are kernel jiffies, Hits holds the time of each
+// of the packets that are remembered for the address
+type RecentEntry struct {
+	Address  string   `json:"address" yaml:"address" xml:"address"`
+	TTL      int      `json:"ttl" yaml:"ttl" xml:"ttl"`
+	LastSeen uint64   `json:"last_seen" yaml:"last_seen" xml:"last_seen"`
+	Hits     []uint64 `json:"hits,omitempty" yaml:"hits" xml:"hits"`
+}
+
+// RecentLists returns the names of the recent lists that are currently in use
+func RecentLists() (lists []string, err error) {
+	result, err := run(fmt.Sprintf("ls %s", recentProcPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recent lists: %w: %s", err, strings.TrimSpace(result))
+	}
+	return strings.Fields(result), nil
+}
+
+// ListRecent returns the entries of the recent list
+func ListRecent(list string) (entries []RecentEntry, err error) {
+	if err = validateRecentList(list); err != nil {
+		return nil, err
+	}
+	result, err := run(fmt.Sprintf("cat %s", path.Join(recentProcPath, list)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recent list %s: %w: %s", list, err, strings.TrimSpace(result))
+	}
+	return parseRecentEntries(result)
+}
+
+// AddRecent adds the address to the recent list or updates it if it is already in the list
+func AddRecent(list string, address string) error {
+	return writeRecent(list, address, "+")
+}
+
+// RemoveRecent removes the address from the recent list
+func RemoveRecent(list string, address string) error {
+	return writeRecent(list, address, "-")
+}
+
+// FlushRecent removes every address from the recent list
+func FlushRecent(list string) error {
+	return writeRecent(list, "", "/")
+}
+
+// writeRecent writes a command to the /proc file of the recent list. The write is done through tee so it goes
+// through the executor like every other command
+func writeRecent(list string, address string, command string) error {
+	if err := validateRecentList(list); err != nil {
+		return err
+	}
+	if command != "/" && net.ParseIP(address) == nil {
+		return fmt.Errorf("invalid recent address %s", address)
+	}
+	result, err := runWithInput(fmt.Sprintf("tee %s", path.Join(recentProcPath, list)), command+address+"\n")
+	if err != nil {
+		return fmt.Errorf("failed to update recent list %s: %w: %s", list, err, strings.TrimSpace(result))
+	}
+	return nil
+}
+
+// parseRecentEntries parses the contents of a /proc/net/xt_recent file. Each line has the format
+// 'src=<address> ttl: <ttl> last_seen: <jiffies> oldest_pkt: <index> <jiffies>, <jiffies>, ...'
+func parseRecentEntries(output string) (entries []RecentEntry, err error) {
+	entries = make([]RecentEntry, 0)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 8 || !strings.HasPrefix(fields[0], "src=") {
+			return nil, fmt.Errorf("invalid recent entry: %s", line)
+		}
+		entry := RecentEntry{
+			Address: strings.TrimPrefix(fields[0], "src="),
+			Hits:    make([]uint64, 0),
+		}
+		entry.TTL, err = strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid recent entry ttl: %s", line)
+		}
+		entry.LastSeen, err = strconv.ParseUint(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recent entry last seen: %s", line)
+		}
+		for _, hit := range strings.Split(strings.Join(fields[7:], ""), ",") {
+			if hit == "" {
+				continue
+			}
+			v, err := strconv.ParseUint(hit, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recent entry hit: %s", line)
+			}
+			entry.Hits = append(entry.Hits, v)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -3,7 +3,6 @@ package iptables
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/BGrewell/go-execute"
 	"log"
 	"strconv"
 	"strings"
@@ -56,7 +55,7 @@ type Rule struct {
 	Applied                bool     `json:"applied,omitempty" yaml:"applied" xml:"applied"`
 	Number                 int      `json:"rule_number,omitempty" yaml:"rule_number" xml:"rule_number"`
 	Debug                  bool     `json:"debug,omitempty" yaml:"debug" xml:"debug"`
-	command                Cmd      `json:"command,omitempty" yaml:"command" xml:"command"`
+	command                Cmd
 }
 
 func (r *Rule) UnmarshalJSON(b []byte) error {
@@ -182,6 +181,20 @@ func (r *Rule) UnmarshalJSON(b []byte) error {
 				return err
 			}
 			r.Target = &tt
+		case "mark":
+			var tt TargetMark
+			err = json.Unmarshal(tjson, &tt)
+			if err != nil {
+				return err
+			}
+			r.Target = &tt
+		case "ct":
+			var tt TargetCT
+			err = json.Unmarshal(tjson, &tt)
+			if err != nil {
+				return err
+			}
+			r.Target = &tt
 		case "dnat":
 			var tt TargetDNat
 			err = json.Unmarshal(tjson, &tt)
@@ -217,6 +230,13 @@ func (r *Rule) UnmarshalJSON(b []byte) error {
 				return err
 			}
 			r.Target = &tt
+		case "log":
+			var tt TargetLog
+			err = json.Unmarshal(tjson, &tt)
+			if err != nil {
+				return err
+			}
+			r.Target = &tt
 		case "masquerade":
 			var tt TargetMasquerade
 			err = json.Unmarshal(tjson, &tt)
@@ -231,6 +251,20 @@ func (r *Rule) UnmarshalJSON(b []byte) error {
 				return err
 			}
 			r.Target = &tt
+		case "nflog":
+			var tt TargetNFLog
+			err = json.Unmarshal(tjson, &tt)
+			if err != nil {
+				return err
+			}
+			r.Target = &tt
+		case "nfqueue":
+			var tt TargetNFQueue
+			err = json.Unmarshal(tjson, &tt)
+			if err != nil {
+				return err
+			}
+			r.Target = &tt
 		case "reject":
 			var tt TargetReject
 			err = json.Unmarshal(tjson, &tt)
@@ -238,6 +272,20 @@ func (r *Rule) UnmarshalJSON(b []byte) error {
 				return err
 			}
 			r.Target = &tt
+		case "tcpmss":
+			var tt TargetTCPMSS
+			err = json.Unmarshal(tjson, &tt)
+			if err != nil {
+				return err
+			}
+			r.Target = &tt
+		case "tproxy":
+			var tt TargetTProxy
+			err = json.Unmarshal(tjson, &tt)
+			if err != nil {
+				return err
+			}
+			r.Target = &tt
 		case "redirect":
 			var tt TargetRedirect
 			err = json.Unmarshal(tjson, &tt)
@@ -245,6 +293,13 @@ func (r *Rule) UnmarshalJSON(b []byte) error {
 				return err
 			}
 			r.Target = &tt
+		case "set":
+			var tt TargetSet
+			err = json.Unmarshal(tjson, &tt)
+			if err != nil {
+				return err
+			}
+			r.Target = &tt
 		case "checksum":
 			var tt TargetChecksum
 			err = json.Unmarshal(tjson, &tt)
@@ -337,7 +392,7 @@ func (r *Rule) execute() (err error) {
 		log.Println(r.String())
 	}
 
-	result, err := execute.ExecuteCmd(r.String())
+	result, err := run(r.String())
 	if r.Debug {
 		log.Println(result)
 	}
@@ -356,9 +411,9 @@ func (r *Rule) updateRuleNumber() {
 		log.Println("unable to update rule number as rule has no id")
 		return
 	}
-	ipt, _ := GetIptablesBinaryPath(string(r.IpVersion))
+	ipt, _ := iptablesCommand(r.IpVersion)
 	listCmd := fmt.Sprintf("%s -t %s -vnL %s --line-numbers", ipt, r.Table, r.Chain)
-	result, err := execute.ExecuteCmd(listCmd)
+	result, err := run(listCmd)
 	if err != nil {
 		log.Printf("error failed to get rule number: %s\n", err)
 	}
@@ -378,7 +433,7 @@ func (r *Rule) String() string {
 
 	r.setDefaults()
 	var output = make([]string, 0)
-	binaryPath, err := GetIptablesBinaryPath(string(r.IpVersion))
+	binaryPath, err := iptablesCommand(r.IpVersion)
 	if err != nil {
 		panic(err)
 	}
@@ -388,6 +443,17 @@ func (r *Rule) String() string {
 		output = append(output, fmt.Sprintf("-t %s", r.Table))
 	}
 
+	output = append(output, r.commandSpecification()...)
+
+	return strings.Join(output, " ")
+}
+
+// commandSpecification returns the command, chain and rule specification without the binary or table. This is the
+// format used for the lines of an iptables-restore payload
+func (r *Rule) commandSpecification() []string {
+	r.setDefaults()
+	var output = make([]string, 0)
+
 	if r.command != "" {
 		output = append(output, fmt.Sprintf("--%s", r.command))
 
@@ -400,6 +466,13 @@ func (r *Rule) String() string {
 		}
 	}
 
+	return append(output, r.specification()...)
+}
+
+// specification returns the parts of the rule that are used by iptables to match it
+func (r *Rule) specification() []string {
+	var output = make([]string, 0)
+
 	if r.Protocol != "" {
 		n := GetNegatedPattern(r.ProtocolNegated)
 		output = append(output, fmt.Sprintf("%s--protocol %s", n, r.Protocol))
@@ -436,47 +509,49 @@ func (r *Rule) String() string {
 	}
 
 	for _, match := range r.Matches {
-		matchValue := match.Value()
-		if match.Name() == "comment" {
-			matchValue = strconv.Quote(match.Value())
-		}
-		n := GetNegatedPattern(match.Negated())
-		output = append(output, fmt.Sprintf("--match %s %s--%s %s", match.Name(), n, match.Option(), matchValue))
+		output = append(output, match.String())
 	}
 
 	if r.Id != "" {
-		output = append(output, fmt.Sprintf("-m comment --comment id:%s", r.Id))
+		output = append(output, fmt.Sprintf("-m comment --comment %s", saveString("id:"+r.Id)))
 	}
 
 	if r.Name != "" {
-		output = append(output, fmt.Sprintf("-m comment --comment name:%s", r.Name))
+		output = append(output, fmt.Sprintf("-m comment --comment %s", saveString("name:"+r.Name)))
 	}
 
 	if r.Target != nil {
 		output = append(output, r.Target.String())
 	}
 
-	return strings.Join(output, " ")
+	return output
 }
 
 func (r *Rule) Validate() (err error) {
-	// Check if target is valid
-	if err := r.Target.Validate(*r); err != nil {
+	if err := r.validateSpec(); err != nil {
 		return err
 	}
 
-	// Check to make sure id doesn't exist
-	if IdExists(r.Id) && (r.command == CmdInsert || r.command == CmdAppend) {
-		return fmt.Errorf("a rule with the id %s already exists", r.Id)
-	}
+	if r.command == CmdInsert || r.command == CmdAppend {
+		// Check to make sure id doesn't exist
+		exists, err := idExists([]IPVer{r.IpVersion}, r.Id)
+		if err != nil {
+			return fmt.Errorf("failed to check if a rule with the id %s exists: %w", r.Id, err)
+		} else if exists {
+			return fmt.Errorf("a rule with the id %s already exists", r.Id)
+		}
 
-	// Check to make sure name doesn't exist
-	if NameExists(r.Name) && (r.command == CmdInsert || r.command == CmdAppend) {
-		return fmt.Errorf("a rule with the name %s already exists", r.Name)
+		// Check to make sure name doesn't exist
+		exists, err = nameExists([]IPVer{r.IpVersion}, r.Name)
+		if err != nil {
+			return fmt.Errorf("failed to check if a rule with the name %s exists: %w", r.Name, err)
+		} else if exists {
+			return fmt.Errorf("a rule with the name %s already exists", r.Name)
+		}
 	}
 
 	// Make sure we are running as root so we can manipulate iptables
-	if !RunningAsRoot() {
+	if requiresRoot() && !RunningAsRoot() {
 		return fmt.Errorf("this application must be run as root to manipulate ip(6)tables")
 	}
 
@@ -484,6 +559,32 @@ func (r *Rule) Validate() (err error) {
 	return nil
 }
 
+// validateSpec checks the target, matches and protocol of the rule without looking at the rules that are already
+// in place
+func (r *Rule) validateSpec() (err error) {
+	// Check if target is valid
+	if err := r.Target.Validate(*r); err != nil {
+		return err
+	}
+
+	// Check if matches are valid
+	for _, match := range r.Matches {
+		if err := match.Validate(*r); err != nil {
+			return err
+		}
+	}
+
+	// Check the protocol belongs to the ip version of the rule
+	if r.IpVersion == IPv6 && r.Protocol == ProtocolICMP {
+		return fmt.Errorf("protocol %s can't be used in an %s rule, use %s", ProtocolICMP, IPv6, ProtocolICMPv6)
+	}
+	if r.IpVersion != IPv6 && IsICMPv6Protocol(r.Protocol) {
+		return fmt.Errorf("protocol %s can only be used in an %s rule", r.Protocol, IPv6)
+	}
+
+	return nil
+}
+
 func (r *Rule) Parse(table string, ruleLine string) (err error) {
 	r.Table, err = ConvertToTable(table)
 	if err != nil {
@@ -491,7 +592,11 @@ func (r *Rule) Parse(table string, ruleLine string) (err error) {
 		return err
 	}
 
-	fields := strings.Fields(ruleLine)
+	fields, err := splitArgs(ruleLine)
+	if err != nil {
+		r.Valid = false
+		return err
+	}
 
 	for idx := 0; idx < len(fields); {
 		negated := false
@@ -533,75 +638,57 @@ func (r *Rule) Parse(table string, ruleLine string) (err error) {
 			r.SourcePort = fields[idx+1]
 			r.SourcePortNegated = negated
 			idx += 2
+		case "--tcp-flags", "--syn", "--tcp-option":
+			// tcp options given without -m tcp are loaded by the implicit tcp match of -p tcp
+			start := idx
+			if negated {
+				start -= 1
+			}
+			options, next := collectMatchOptions(fields, start)
+			idx = next
+			r.parseProtocolMatch(matchTCPName, options)
 		case "-m":
-			var m Match
 			name := fields[idx+1]
-			if fields[idx+2] == "!" {
-				negated = true
-				idx++
-			}
-			option := strings.Replace(fields[idx+2], "--", "", 1)
-			value := fields[idx+3]
-
-			if (name == "tcp" || name == "udp") && (strings.HasPrefix(option, "dport") || strings.HasPrefix(option, "sport")) {
-				if strings.HasPrefix(option, "dport") {
-					r.DestinationPort = value
-					r.DestinationPortNegated = negated
-				} else {
-					r.SourcePort = value
-					r.SourcePortNegated = negated
+			options, next := collectMatchOptions(fields, idx+2)
+			idx = next
+
+			switch name {
+			case "tcp", "udp":
+				r.parseProtocolMatch(name, options)
+			case matchCommentName:
+				m := &MatchComment{}
+				err = m.Parse(options)
+				if err != nil {
+					return err
 				}
-			} else {
-				if fields[idx+1] == "comment" {
-					m = &MatchComment{}
-					comment := make([]string, 0)
-					for !strings.HasPrefix(fields[idx+3], "-") {
-						comment = append(comment, fields[idx+3])
-						idx++
-					}
-					idx-- // rewind the index
-
-					value = strings.Join(comment, " ")
-					value = strings.ReplaceAll(value, "\\\"", "")
-					value = strings.ReplaceAll(value, "\"", "")
-					value = strings.ReplaceAll(value, "'", "")
-					if strings.Contains(value, ":") {
-						parts := strings.Split(value, ":")
-						if len(parts) == 2 {
-							mark := &MarkerGeneric{
-								name:  parts[0],
-								value: parts[1],
-							}
-							r.AddMarker(mark)
+				value := m.Value()
+				if strings.Contains(value, ":") {
+					parts := strings.Split(value, ":")
+					if len(parts) == 2 {
+						mark := &MarkerGeneric{
+							name:  parts[0],
+							value: parts[1],
 						}
-					}
-				} else {
-					m = &MatchGeneric{}
-					err = m.SetName(name)
-					if err != nil {
-						return err
-					}
-					err = m.SetOption(option)
-					if err != nil {
-						return err
+						r.AddMarker(mark)
 					}
 				}
-				err = m.SetValue(value)
-				if err != nil {
-					return err
-				}
-				m.SetNegated(negated)
 
-				if m.Name() == "comment" && strings.HasPrefix(m.Value(), "id:") {
-					r.Id = strings.Replace(m.Value(), "id:", "", 1)
-				} else if m.Name() == "comment" && strings.HasPrefix(m.Value(), "name:") {
-					r.Name = strings.Replace(m.Value(), "name:", "", 1)
+				if strings.HasPrefix(value, "id:") {
+					r.Id = strings.Replace(value, "id:", "", 1)
+				} else if strings.HasPrefix(value, "name:") {
+					r.Name = strings.Replace(value, "name:", "", 1)
 				} else {
 					r.AddMatch(m)
 				}
+			default:
+				var m Match = newMatch(name)
+				if perr := m.Parse(options); perr != nil {
+					log.Printf("unable to parse %s match, keeping it as a generic match: %s\n", name, perr)
+					log.Printf("line: %s\n", ruleLine)
+					m = NewMatchGenericWithOptions(name, options...)
+				}
+				r.AddMatch(m)
 			}
-
-			idx += 4
 		case "-j", "-g":
 			target := fields[idx+1]
 			switch target {
@@ -640,9 +727,8 @@ func (r *Rule) Parse(table string, ruleLine string) (err error) {
 				r.Target = t
 			case "REJECT":
 				t := &TargetReject{}
-				t.Parse(fields[idx+2], fields[idx+3])
+				idx = parseTargetOptions(t, fields, idx+2)
 				r.Target = t
-				idx += 4
 			case "REDIRECT":
 				t := &TargetRedirect{}
 				t.Parse(fields[idx+2], fields[idx+3])
@@ -653,8 +739,47 @@ func (r *Rule) Parse(table string, ruleLine string) (err error) {
 				t.Parse(fields[idx+2], "")
 				r.Target = t
 				idx += 3
+			case "MARK":
+				t := &TargetMark{}
+				idx = parseTargetOptions(t, fields, idx+2)
+				r.Target = t
+			case "CONNMARK":
+				t := &TargetConnMark{}
+				idx = parseTargetOptions(t, fields, idx+2)
+				r.Target = t
+			case "SET":
+				t := &TargetSet{}
+				idx = parseTargetOptions(t, fields, idx+2)
+				r.Target = t
+			case "LOG":
+				t := &TargetLog{}
+				idx = parseTargetOptions(t, fields, idx+2)
+				r.Target = t
+			case "NFLOG":
+				t := &TargetNFLog{}
+				idx = parseTargetOptions(t, fields, idx+2)
+				r.Target = t
+			case "NFQUEUE":
+				t := &TargetNFQueue{}
+				idx = parseTargetOptions(t, fields, idx+2)
+				r.Target = t
+			case "CT":
+				t := &TargetCT{}
+				idx = parseTargetOptions(t, fields, idx+2)
+				r.Target = t
+			case "NOTRACK":
+				r.Target = &TargetCT{NoTrack: true, UseNoTrackTarget: true}
+				idx += 2
+			case "TPROXY":
+				t := &TargetTProxy{}
+				idx = parseTargetOptions(t, fields, idx+2)
+				r.Target = t
+			case "TCPMSS":
+				t := &TargetTCPMSS{}
+				idx = parseTargetOptions(t, fields, idx+2)
+				r.Target = t
 			default:
-				if !ValidChain(table, target) {
+				if !validChain(r.IpVersion, table, target) {
 					log.Printf("unknown target %s\n", target)
 					log.Printf("line: %s\n", ruleLine)
 				}
@@ -679,6 +804,46 @@ func (r *Rule) Parse(table string, ruleLine string) (err error) {
 	return nil
 }
 
+// parseProtocolMatch stores the ports of a tcp or udp match on the rule itself, any other options are kept in a match
+func (r *Rule) parseProtocolMatch(name string, options []MatchOption) {
+	remaining := make([]MatchOption, 0)
+	for _, option := range options {
+		if strings.HasPrefix(option.Option, "dport") {
+			r.DestinationPort = option.Value
+			r.DestinationPortNegated = option.Negated
+		} else if strings.HasPrefix(option.Option, "sport") {
+			r.SourcePort = option.Value
+			r.SourcePortNegated = option.Negated
+		} else {
+			remaining = append(remaining, option)
+		}
+	}
+	if len(remaining) == 0 {
+		return
+	}
+
+	var m Match = NewMatchGenericWithOptions(name, remaining...)
+	if name == matchTCPName {
+		tcp := &MatchTCP{}
+		if err := tcp.Parse(remaining); err != nil {
+			log.Printf("unable to parse tcp match, keeping it as a generic match: %s\n", err)
+		} else {
+			m = tcp
+		}
+	}
+	r.AddMatch(m)
+}
+
+// parseTargetOptions passes each of the options starting at idx to the target and returns the index of the first
+// field that isn't part of the target
+func parseTargetOptions(t Target, fields []string, idx int) int {
+	options, next := collectMatchOptions(fields, idx)
+	for _, option := range options {
+		t.Parse(fmt.Sprintf("--%s", option.Option), option.Value)
+	}
+	return next
+}
+
 func (r *Rule) Append() (err error) {
 	r.command = CmdAppend
 	return r.execute()
@@ -700,6 +865,64 @@ func (r *Rule) Delete() (err error) {
 	return r.execute()
 }
 
+// Exists uses the check command to determine if a rule with exactly the same specification exists in the chain
+func (r *Rule) Exists() (exists bool, err error) {
+	if requiresRoot() && !RunningAsRoot() {
+		return false, fmt.Errorf("this application must be run as root to manipulate ip(6)tables")
+	}
+
+	// The check only borrows the command, restore it so the rule can still be used for what it was set up for
+	previous := r.command
+	defer func() {
+		r.command = previous
+	}()
+	r.command = CmdCheck
+	if r.Debug {
+		log.Println(r.String())
+	}
+
+	result, err := run(r.String())
+	if r.Debug {
+		log.Println(result)
+	}
+
+	if err != nil {
+		if strings.Contains(result, "does a matching rule exist") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check rule: %w: %s", err, strings.TrimSpace(result))
+	}
+
+	return true, nil
+}
+
+// EnsureAppended appends the rule only if a rule with the same specification doesn't already exist in the chain
+func (r *Rule) EnsureAppended() (err error) {
+	exists, err := r.Exists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		r.setState(true, true)
+		return nil
+	}
+	return r.Append()
+}
+
+// EnsureInserted inserts the rule at index only if a rule with the same specification doesn't already exist in the
+// chain. The position of an existing rule is not checked
+func (r *Rule) EnsureInserted(index int) (err error) {
+	exists, err := r.Exists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		r.setState(true, true)
+		return nil
+	}
+	return r.Insert(index)
+}
+
 func (r *Rule) SetApp(app string) {
 	mark := MarkerGeneric{}
 	mark.SetName("app")
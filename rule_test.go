@@ -0,0 +1,445 @@
+package iptables
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRuleParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		ipVer   IPVer
+		table   string
+		line    string
+		want    string
+		matches []string
+		target  string
+	}{
+		{
+			name:    "conntrack",
+			table:   "filter",
+			line:    "-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --match conntrack --ctstate RELATED,ESTABLISHED --jump ACCEPT",
+			matches: []string{"*iptables.MatchConntrack"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "state",
+			table:   "filter",
+			line:    "-A INPUT -m state --state NEW -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --match state --state NEW --jump ACCEPT",
+			matches: []string{"*iptables.MatchConntrack"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "comment",
+			table:   "filter",
+			line:    `-A INPUT -m comment --comment "allow ssh" -j ACCEPT`,
+			want:    `iptables -t filter --append INPUT --match comment --comment "allow ssh" --jump ACCEPT`,
+			matches: []string{"*iptables.MatchComment"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:   "id and name comments",
+			table:  "filter",
+			line:   `-A INPUT -m comment --comment "id:abc" -m comment --comment "name:web rule" -j ACCEPT`,
+			want:   `iptables -t filter --append INPUT -m comment --comment "id:abc" -m comment --comment "name:web rule" --jump ACCEPT`,
+			target: "*iptables.TargetJump",
+		},
+		{
+			name:    "set",
+			table:   "filter",
+			line:    "-A INPUT -m set --match-set blocked src -j DROP",
+			want:    "iptables -t filter --append INPUT --match set --match-set blocked src --jump DROP",
+			matches: []string{"*iptables.MatchSet"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "limit",
+			table:   "filter",
+			line:    "-A INPUT -m limit --limit 5/sec --limit-burst 10 -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --match limit --limit 5/sec --limit-burst 10 --jump ACCEPT",
+			matches: []string{"*iptables.MatchLimit"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "hashlimit with byte units",
+			table:   "filter",
+			line:    "-A INPUT -m hashlimit --hashlimit-upto 1kb/s --hashlimit-burst 2mb --hashlimit-mode srcip --hashlimit-name web -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --match hashlimit --hashlimit-upto 1kb/s --hashlimit-burst 2mb --hashlimit-mode srcip --hashlimit-name web --jump ACCEPT",
+			matches: []string{"*iptables.MatchHashLimit"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "connlimit of zero",
+			table:   "filter",
+			line:    "-A INPUT -p tcp -m tcp --dport 22 -m connlimit --connlimit-above 0 --connlimit-mask 32 --connlimit-saddr -j REJECT --reject-with tcp-reset",
+			want:    "iptables -t filter --append INPUT --protocol tcp --match multiport --dports 22 --match connlimit --connlimit-above 0 --connlimit-mask 32 --connlimit-saddr --jump REJECT --reject-with tcp-reset",
+			matches: []string{"*iptables.MatchConnLimit"},
+			target:  "*iptables.TargetReject",
+		},
+		{
+			name:    "negated mark",
+			table:   "filter",
+			line:    "-A INPUT -m mark ! --mark 0x1/0xff -j DROP",
+			want:    "iptables -t filter --append INPUT --match mark ! --mark 0x1/0xff --jump DROP",
+			matches: []string{"*iptables.MatchMark"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "connmark restore",
+			table:   "mangle",
+			line:    "-A PREROUTING -m connmark --mark 0x2 -j CONNMARK --restore-mark --nfmask 0xff --ctmask 0xff",
+			want:    "iptables -t mangle --append PREROUTING --match connmark --mark 0x2 --jump CONNMARK --restore-mark --nfmask 0xff --ctmask 0xff",
+			matches: []string{"*iptables.MatchConnMark"},
+			target:  "*iptables.TargetConnMark",
+		},
+		{
+			name:   "mark set with full mask",
+			table:  "mangle",
+			line:   "-A PREROUTING -j MARK --set-xmark 0x1/0xffffffff",
+			want:   "iptables -t mangle --append PREROUTING --jump MARK --set-mark 0x1",
+			target: "*iptables.TargetMark",
+		},
+		{
+			name:   "mark set with mask",
+			table:  "mangle",
+			line:   "-A PREROUTING -j MARK --set-xmark 0x1/0xff",
+			want:   "iptables -t mangle --append PREROUTING --jump MARK --set-mark 0x1/0xff",
+			target: "*iptables.TargetMark",
+		},
+		{
+			name:   "connmark or",
+			table:  "mangle",
+			line:   "-A PREROUTING -j CONNMARK --set-xmark 0x4/0x4",
+			want:   "iptables -t mangle --append PREROUTING --jump CONNMARK --or-mark 0x4",
+			target: "*iptables.TargetConnMark",
+		},
+		{
+			name:    "physdev",
+			table:   "filter",
+			line:    "-A FORWARD -m physdev --physdev-in eth0 --physdev-out eth1 --physdev-is-bridged -j ACCEPT",
+			want:    "iptables -t filter --append FORWARD --match physdev --physdev-in eth0 --physdev-out eth1 --physdev-is-bridged --jump ACCEPT",
+			matches: []string{"*iptables.MatchPhysDev"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "time",
+			table:   "filter",
+			line:    "-A INPUT -m time --timestart 08:00:00 --timestop 17:00:00 --weekdays Mon,Tue --kerneltz -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --match time --timestart 08:00:00 --timestop 17:00:00 --weekdays Mon,Tue --kerneltz --jump ACCEPT",
+			matches: []string{"*iptables.MatchTime"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "string",
+			table:   "filter",
+			line:    `-A INPUT -m string --string "GET /" --algo bm --to 65535 -j DROP`,
+			want:    `iptables -t filter --append INPUT --match string --string "GET /" --algo bm --to 65535 --jump DROP`,
+			matches: []string{"*iptables.MatchString"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "u32",
+			table:   "filter",
+			line:    `-A INPUT -m u32 --u32 "0x0>>0x16&0x3c@0x4>>0x10=0x50" -j DROP`,
+			want:    `iptables -t filter --append INPUT --match u32 --u32 "0x0>>0x16&0x3c@0x4>>0x10=0x50" --jump DROP`,
+			matches: []string{"*iptables.MatchU32"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "bpf",
+			table:   "filter",
+			line:    `-A INPUT -m bpf --bytecode "4,48 0 0 9,21 0 1 6,6 0 0 1,6 0 0 0" -j ACCEPT`,
+			want:    `iptables -t filter --append INPUT --match bpf --bytecode "4,48 0 0 9,21 0 1 6,6 0 0 1,6 0 0 0" --jump ACCEPT`,
+			matches: []string{"*iptables.MatchBPF"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "owner",
+			table:   "filter",
+			line:    "-A OUTPUT -m owner --uid-owner 1000 -j ACCEPT",
+			want:    "iptables -t filter --append OUTPUT --match owner --uid-owner 1000 --jump ACCEPT",
+			matches: []string{"*iptables.MatchOwner"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "cgroup",
+			table:   "filter",
+			line:    "-A OUTPUT -m cgroup --path user.slice -j ACCEPT",
+			want:    `iptables -t filter --append OUTPUT --match cgroup --path "user.slice" --jump ACCEPT`,
+			matches: []string{"*iptables.MatchCgroup"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "socket",
+			table:   "mangle",
+			line:    "-A PREROUTING -p tcp -m socket --transparent -j MARK --set-xmark 0x1/0x1",
+			want:    "iptables -t mangle --append PREROUTING --protocol tcp --match socket --transparent --jump MARK --or-mark 0x1",
+			matches: []string{"*iptables.MatchSocket"},
+			target:  "*iptables.TargetMark",
+		},
+		{
+			name:    "recent",
+			table:   "filter",
+			line:    "-A INPUT -m recent --rcheck --seconds 60 --hitcount 4 --name ssh --mask 255.255.255.255 --rsource -j DROP",
+			want:    "iptables -t filter --append INPUT --match recent --rcheck --seconds 60 --hitcount 4 --name ssh --mask 255.255.255.255 --rsource --jump DROP",
+			matches: []string{"*iptables.MatchRecent"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "addrtype",
+			table:   "filter",
+			line:    "-A INPUT -m addrtype --dst-type LOCAL -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --match addrtype --dst-type LOCAL --jump ACCEPT",
+			matches: []string{"*iptables.MatchAddrType"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "pkttype",
+			table:   "filter",
+			line:    "-A INPUT -m pkttype --pkt-type broadcast -j DROP",
+			want:    "iptables -t filter --append INPUT --match pkttype --pkt-type broadcast --jump DROP",
+			matches: []string{"*iptables.MatchPktType"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "iprange",
+			table:   "filter",
+			line:    "-A INPUT -m iprange --src-range 10.0.0.1-10.0.0.9 -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --match iprange --src-range 10.0.0.1-10.0.0.9 --jump ACCEPT",
+			matches: []string{"*iptables.MatchIpRange"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "mac",
+			table:   "filter",
+			line:    "-A INPUT -m mac --mac-source AA:BB:CC:DD:EE:FF -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --match mac --mac-source AA:BB:CC:DD:EE:FF --jump ACCEPT",
+			matches: []string{"*iptables.MatchMac"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "icmp",
+			table:   "filter",
+			line:    "-A INPUT -p icmp -m icmp --icmp-type 8 -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --protocol icmp --match icmp --icmp-type 8 --jump ACCEPT",
+			matches: []string{"*iptables.MatchICMP"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "icmp6",
+			ipVer:   IPv6,
+			table:   "filter",
+			line:    "-A INPUT -p ipv6-icmp -m icmp6 --icmpv6-type 128 -j ACCEPT",
+			want:    "ip6tables -t filter --append INPUT --protocol ipv6-icmp --match icmp6 --icmpv6-type 128 --jump ACCEPT",
+			matches: []string{"*iptables.MatchICMPv6"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "tcp ports and flags",
+			table:   "filter",
+			line:    "-A INPUT -p tcp -m tcp --sport 1024:65535 --dport 443 --tcp-flags SYN,RST,ACK,FIN SYN -j ACCEPT",
+			want:    "iptables -t filter --append INPUT --protocol tcp --match multiport --sports 1024:65535 --match multiport --dports 443 --match tcp --tcp-flags SYN,RST,ACK,FIN SYN --jump ACCEPT",
+			matches: []string{"*iptables.MatchTCP"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:    "negated tcp option",
+			table:   "filter",
+			line:    "-A INPUT -p tcp -m tcp ! --tcp-option 2 -j DROP",
+			want:    "iptables -t filter --append INPUT --protocol tcp --match tcp ! --tcp-option 2 --jump DROP",
+			matches: []string{"*iptables.MatchTCP"},
+			target:  "*iptables.TargetJump",
+		},
+		{
+			name:   "udp ports",
+			table:  "filter",
+			line:   "-A INPUT -p udp -m udp --dport 53 -j ACCEPT",
+			want:   "iptables -t filter --append INPUT --protocol udp --match multiport --dports 53 --jump ACCEPT",
+			target: "*iptables.TargetJump",
+		},
+		{
+			name:    "tcpmss clamp",
+			table:   "mangle",
+			line:    "-A FORWARD -p tcp -m tcp --tcp-flags SYN,RST SYN -m tcpmss --mss 1400:1536 -j TCPMSS --clamp-mss-to-pmtu",
+			want:    "iptables -t mangle --append FORWARD --protocol tcp --match tcp --tcp-flags SYN,RST SYN --match tcpmss --mss 1400:1536 --jump TCPMSS --clamp-mss-to-pmtu",
+			matches: []string{"*iptables.MatchTCP", "*iptables.MatchTCPMSS"},
+			target:  "*iptables.TargetTCPMSS",
+		},
+		{
+			name:   "tcpmss set",
+			table:  "mangle",
+			line:   "-A FORWARD -p tcp -j TCPMSS --set-mss 1360",
+			want:   "iptables -t mangle --append FORWARD --protocol tcp --jump TCPMSS --set-mss 1360",
+			target: "*iptables.TargetTCPMSS",
+		},
+		{
+			name:   "reject with type",
+			table:  "filter",
+			line:   "-A INPUT -j REJECT --reject-with icmp-port-unreachable",
+			want:   "iptables -t filter --append INPUT --jump REJECT --reject-with icmp-port-unreachable",
+			target: "*iptables.TargetReject",
+		},
+		{
+			name:   "reject without type",
+			table:  "filter",
+			line:   "-A INPUT -j REJECT",
+			want:   "iptables -t filter --append INPUT --jump REJECT",
+			target: "*iptables.TargetReject",
+		},
+		{
+			name:   "log",
+			table:  "filter",
+			line:   `-A INPUT -j LOG --log-prefix "dropped: " --log-level 4 --log-uid`,
+			want:   `iptables -t filter --append INPUT --jump LOG --log-prefix "dropped: " --log-level 4 --log-uid`,
+			target: "*iptables.TargetLog",
+		},
+		{
+			name:   "nflog",
+			table:  "filter",
+			line:   "-A INPUT -j NFLOG --nflog-prefix dropped --nflog-group 2",
+			want:   "iptables -t filter --append INPUT --jump NFLOG --nflog-prefix dropped --nflog-group 2",
+			target: "*iptables.TargetNFLog",
+		},
+		{
+			name:   "nfqueue",
+			table:  "filter",
+			line:   "-A INPUT -j NFQUEUE --queue-balance 0:3 --queue-bypass --queue-cpu-fanout",
+			want:   "iptables -t filter --append INPUT --jump NFQUEUE --queue-balance 0:3 --queue-bypass --queue-cpu-fanout",
+			target: "*iptables.TargetNFQueue",
+		},
+		{
+			name:   "ct notrack",
+			table:  "raw",
+			line:   "-A PREROUTING -j CT --notrack",
+			want:   "iptables -t raw --append PREROUTING --jump CT --notrack",
+			target: "*iptables.TargetCT",
+		},
+		{
+			name:   "notrack",
+			table:  "raw",
+			line:   "-A PREROUTING -j NOTRACK",
+			want:   "iptables -t raw --append PREROUTING --jump NOTRACK",
+			target: "*iptables.TargetCT",
+		},
+		{
+			name:   "ct helper",
+			table:  "raw",
+			line:   "-A PREROUTING -p tcp -m tcp --dport 21 -j CT --helper ftp --zone 2",
+			want:   "iptables -t raw --append PREROUTING --protocol tcp --match multiport --dports 21 --jump CT --helper ftp --zone 2",
+			target: "*iptables.TargetCT",
+		},
+		{
+			name:   "tproxy",
+			table:  "mangle",
+			line:   "-A PREROUTING -p tcp -j TPROXY --on-port 8080 --on-ip 127.0.0.1 --tproxy-mark 0x1/0x1",
+			want:   "iptables -t mangle --append PREROUTING --protocol tcp --jump TPROXY --on-port 8080 --on-ip 127.0.0.1 --tproxy-mark 0x1/0x1",
+			target: "*iptables.TargetTProxy",
+		},
+		{
+			name:   "set target",
+			table:  "filter",
+			line:   "-A INPUT -j SET --add-set seen src --exist --timeout 60",
+			want:   "iptables -t filter --append INPUT --jump SET --add-set seen src --timeout 60 --exist",
+			target: "*iptables.TargetSet",
+		},
+		{
+			name:    "generic match",
+			table:   "filter",
+			line:    `-A INPUT -m foo --bar "x y" -j ACCEPT`,
+			want:    `iptables -t filter --append INPUT --match foo --bar "x y" --jump ACCEPT`,
+			matches: []string{"*iptables.MatchGeneric"},
+			target:  "*iptables.TargetJump",
+		},
+	}
+
+	useFakeExecutor(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rule{IpVersion: tt.ipVer}
+			if r.IpVersion == "" {
+				r.IpVersion = IPv4
+			}
+			if err := r.Parse(tt.table, tt.line); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			matches := make([]string, 0)
+			for _, m := range r.Matches {
+				matches = append(matches, fmt.Sprintf("%T", m))
+			}
+			if len(tt.matches) == 0 {
+				tt.matches = []string{}
+			}
+			if !reflect.DeepEqual(matches, tt.matches) {
+				t.Errorf("got matches %v, want %v", matches, tt.matches)
+			}
+			if target := fmt.Sprintf("%T", r.Target); target != tt.target {
+				t.Errorf("got target %s, want %s", target, tt.target)
+			}
+
+			r.command = CmdAppend
+			if got := r.String(); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		line  string
+	}{
+		{name: "unknown table", table: "bogus", line: "-A INPUT -j ACCEPT"},
+		{name: "unterminated quote", table: "filter", line: `-A INPUT -m comment --comment "open -j ACCEPT`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rule{IpVersion: IPv4}
+			if err := r.Parse(tt.table, tt.line); err == nil {
+				t.Errorf("expected an error parsing %q", tt.line)
+			}
+		})
+	}
+}
+
+func TestRuleExists(t *testing.T) {
+	check := "iptables -t filter --check INPUT --protocol tcp -m comment --comment \"id:ssh\" --jump ACCEPT"
+	tests := []struct {
+		name    string
+		output  string
+		err     string
+		want    bool
+		wantErr bool
+	}{
+		{name: "exists", want: true},
+		{name: "missing", output: "iptables: Bad rule (does a matching rule exist in that chain?).", err: "exit status 1"},
+		{name: "failure", output: "iptables: No chain/target/match by that name.", err: "exit status 1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeExecutor(t, ExecRecord{Command: check, Output: tt.output, Error: tt.err})
+			r := NewRule("ssh", nil)
+			r.Table = TableFilter
+			r.Chain = ChainInput
+			r.Protocol = ProtocolTCP
+			r.Target = &TargetJump{Value: "ACCEPT"}
+			r.command = CmdDelete
+
+			exists, err := r.Exists()
+			if (err != nil) != tt.wantErr || exists != tt.want {
+				t.Errorf("got %v, %v, want %v and an error %v", exists, err, tt.want, tt.wantErr)
+			}
+			if commands := fake.Commands(); len(commands) != 1 || commands[0] != check {
+				t.Errorf("got commands %q, want %q", commands, check)
+			}
+			if r.command != CmdDelete {
+				t.Errorf("got command %s after the check, want %s", r.command, CmdDelete)
+			}
+		})
+	}
+}
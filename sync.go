@@ -2,7 +2,6 @@ package iptables
 
 import (
 	"fmt"
-	"github.com/BGrewell/go-execute"
 	"github.com/google/uuid"
 	"log"
 	"reflect"
@@ -10,54 +9,131 @@ import (
 )
 
 func Sync() (rules []*Rule, err error) {
+	rules, _, err = syncRules(false)
+	return rules, err
+}
+
+// SyncWithCounters reads the rules using ip(6)tables-save so that the packet and byte counters of every rule are
+// populated. The policies of the built-in chains are returned along with their counters
+func SyncWithCounters() (rules []*Rule, policies []*ChainPolicy, err error) {
+	return syncRules(true)
+}
+
+func syncRules(counters bool) (rules []*Rule, policies []*ChainPolicy, err error) {
 
-	if !RunningAsRoot() {
-		return nil, fmt.Errorf("error you must run this program as root")
+	if requiresRoot() && !RunningAsRoot() {
+		return nil, nil, fmt.Errorf("error you must run this program as root")
 	}
 
 	ipvers := []string{"ipv4", "ipv6"}
 	rules = make([]*Rule, 0)
+	policies = make([]*ChainPolicy, 0)
 
 	for _, ipver := range ipvers {
 
 		var ipt string
-		ipt, err = GetIptablesBinaryPath(ipver)
+		if counters {
+			ipt, err = GetIptablesSaveBinaryPath(ipver)
+		} else {
+			ipt, err = iptablesCommand(IPVer(ipver))
+		}
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		for _, table := range tables {
 
 			var tableRules string
 			cmd := fmt.Sprintf("%s -t %s -S", ipt, table)
-			tableRules, err = execute.ExecuteCmd(cmd)
+			if counters {
+				cmd = fmt.Sprintf("%s -c -t %s", ipt, table)
+			}
+			tableRules, err = run(cmd)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
-			tableLines := strings.Split(tableRules, "\n")
-			ruleNumbers := make(map[string]int)
-			for _, line := range tableLines {
-				if strings.HasPrefix(line, "-A") || strings.HasPrefix(line, "-I") {
-					r := Rule{}
-					r.IpVersion = IPVer(ipver)
-					err = r.Parse(table, line)
-					if err != nil {
-						return nil, err
-					}
-					if _, ok := ruleNumbers[string(r.Chain)]; !ok {
-						ruleNumbers[string(r.Chain)] = 0
-					}
-					ruleNumbers[string(r.Chain)] += 1
-					r.Number = ruleNumbers[string(r.Chain)]
-					rules = append(rules, &r)
-				}
+			r, p, err := parseTable(IPVer(ipver), table, tableRules)
+			if err != nil {
+				return nil, nil, err
 			}
+			rules = append(rules, r...)
+			policies = append(policies, p...)
 		}
 
 	}
 
-	return rules, nil
+	return rules, policies, nil
+}
+
+// parseTable parses the output of either ip(6)tables -S or ip(6)tables-save [-c] for a single table
+func parseTable(ipver IPVer, table string, output string) (rules []*Rule, policies []*ChainPolicy, err error) {
+	rules = make([]*Rule, 0)
+	policies = make([]*ChainPolicy, 0)
+	tableLines := strings.Split(output, "\n")
+	ruleNumbers := make(map[string]int)
+	for _, line := range tableLines {
+		line = strings.TrimSpace(line)
+
+		// Lines from iptables-save -c are prefixed with the [packets:bytes] counters
+		var counter Counter
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end == -1 {
+				return nil, nil, fmt.Errorf("invalid counters on line: %s", line)
+			}
+			counter, err = ParseCounter(line[:end+1])
+			if err != nil {
+				return nil, nil, err
+			}
+			line = strings.TrimSpace(line[end+1:])
+		}
+
+		if strings.HasPrefix(line, "-A") || strings.HasPrefix(line, "-I") {
+			r := Rule{}
+			r.IpVersion = ipver
+			err = r.Parse(table, line)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, ok := ruleNumbers[string(r.Chain)]; !ok {
+				ruleNumbers[string(r.Chain)] = 0
+			}
+			ruleNumbers[string(r.Chain)] += 1
+			r.Number = ruleNumbers[string(r.Chain)]
+			r.Counters = counter
+			rules = append(rules, &r)
+		} else if strings.HasPrefix(line, ":") || strings.HasPrefix(line, "-P") {
+			// iptables-save lists chains as ':CHAIN POLICY [packets:bytes]' and iptables -S as '-P CHAIN POLICY'.
+			// User defined chains have a policy of '-' and are skipped
+			fields := strings.Fields(strings.TrimPrefix(line, ":"))
+			if len(fields) == 0 {
+				continue
+			}
+			if fields[0] == "-P" {
+				fields = fields[1:]
+			}
+			// Checked again as a bare '-P' has nothing after it
+			if len(fields) < 2 || fields[1] == "-" {
+				continue
+			}
+			p := &ChainPolicy{
+				IpVersion: ipver,
+				Table:     Table(table),
+				Chain:     Chain(fields[0]),
+				Policy:    fields[1],
+			}
+			if len(fields) > 2 {
+				p.Counters, err = ParseCounter(fields[2])
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			policies = append(policies, p)
+		}
+	}
+
+	return rules, policies, nil
 }
 
 func LabelRules() (err error) {
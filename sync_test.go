@@ -0,0 +1,110 @@
+package iptables
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	type ruleSummary struct {
+		Chain    Chain
+		Number   int
+		Id       string
+		Counters Counter
+	}
+	tests := []struct {
+		name     string
+		output   string
+		rules    []ruleSummary
+		policies []ChainPolicy
+		wantErr  bool
+	}{
+		{
+			name: "list",
+			output: "-P INPUT DROP\n" +
+				"-P FORWARD ACCEPT\n" +
+				"-N SSH\n" +
+				"-A INPUT -m comment --comment \"id:first\" -j ACCEPT\n" +
+				"-A INPUT -j SSH\n" +
+				"-A SSH -p tcp -m tcp --dport 22 -j ACCEPT\n",
+			rules: []ruleSummary{
+				{Chain: ChainInput, Number: 1, Id: "first"},
+				{Chain: ChainInput, Number: 2},
+				{Chain: "SSH", Number: 1},
+			},
+			policies: []ChainPolicy{
+				{IpVersion: IPv4, Table: TableFilter, Chain: ChainInput, Policy: "DROP"},
+				{IpVersion: IPv4, Table: TableFilter, Chain: ChainForward, Policy: "ACCEPT"},
+			},
+		},
+		{
+			name: "save with counters",
+			output: "# Generated by iptables-save\n" +
+				"*filter\n" +
+				":INPUT ACCEPT [10:840]\n" +
+				":SSH - [0:0]\n" +
+				"[3:180] -A INPUT -j SSH\n" +
+				"[1:60] -A SSH -p tcp -m tcp --dport 22 -j ACCEPT\n" +
+				"COMMIT\n",
+			rules: []ruleSummary{
+				{Chain: ChainInput, Number: 1, Counters: Counter{Packets: 3, Bytes: 180}},
+				{Chain: "SSH", Number: 1, Counters: Counter{Packets: 1, Bytes: 60}},
+			},
+			policies: []ChainPolicy{
+				{IpVersion: IPv4, Table: TableFilter, Chain: ChainInput, Policy: "ACCEPT", Counters: Counter{Packets: 10, Bytes: 840}},
+			},
+		},
+		{
+			name:     "empty chain and policy lines",
+			output:   ":\n-P\n-P INPUT\n\n",
+			rules:    []ruleSummary{},
+			policies: []ChainPolicy{},
+		},
+		{
+			name:    "unterminated counters",
+			output:  "[3:180 -A INPUT -j ACCEPT\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid counters",
+			output:  "[3:x] -A INPUT -j ACCEPT\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid policy counters",
+			output:  ":INPUT ACCEPT [10]\n",
+			wantErr: true,
+		},
+	}
+
+	useFakeExecutor(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, policies, err := parseTable(IPv4, "filter", tt.output)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotRules := make([]ruleSummary, 0)
+			for _, r := range rules {
+				gotRules = append(gotRules, ruleSummary{Chain: r.Chain, Number: r.Number, Id: r.Id, Counters: r.Counters})
+			}
+			if !reflect.DeepEqual(gotRules, tt.rules) {
+				t.Errorf("got rules %+v, want %+v", gotRules, tt.rules)
+			}
+			gotPolicies := make([]ChainPolicy, 0)
+			for _, p := range policies {
+				gotPolicies = append(gotPolicies, *p)
+			}
+			if !reflect.DeepEqual(gotPolicies, tt.policies) {
+				t.Errorf("got policies %+v, want %+v", gotPolicies, tt.policies)
+			}
+		})
+	}
+}
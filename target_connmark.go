@@ -4,52 +4,73 @@ import (
 	"fmt"
 )
 
-//TODO: Need to fully implement by supporting masks for the mark
-
 type ConnMarkType int
 
 const (
 	ConnMarkTypeSet = iota
 	ConnMarkTypeSave
 	ConnMarkTypeRestore
+	ConnMarkTypeSetX
+	ConnMarkTypeAnd
+	ConnMarkTypeOr
+	ConnMarkTypeXor
 )
 
 const (
 	TargetConnMarkSet     string = "--set-mark"
 	TargetConnMarkSave    string = "--save-mark"
 	TargetConnMarkRestore string = "--restore-mark"
+	TargetConnMarkSetX    string = "--set-xmark"
+	TargetConnMarkAnd     string = "--and-mark"
+	TargetConnMarkOr      string = "--or-mark"
+	TargetConnMarkXor     string = "--xor-mark"
+	TargetConnMarkNfMask  string = "--nfmask"
+	TargetConnMarkCtMask  string = "--ctmask"
+	TargetConnMarkMask    string = "--mask"
 )
 
+// TargetConnMark changes the mark of the connection the packet belongs to. Value and Mask are used by the set, and,
+// or and xor types the same way as TargetMark. The save and restore types copy the packet mark to the connection
+// mark and back, NfMask and CtMask select the bits that are copied and zero leaves the default of 0xffffffff
 type TargetConnMark struct {
 	MarkType ConnMarkType `json:"mark_type" yaml:"mark_type" xml:"mark_type"`
 	Value    int          `json:"value" yaml:"value" xml:"value"`
+	Mask     int          `json:"mask,omitempty" yaml:"mask" xml:"mask"`
+	NfMask   int          `json:"nf_mask,omitempty" yaml:"nf_mask" xml:"nf_mask"`
+	CtMask   int          `json:"ct_mask,omitempty" yaml:"ct_mask" xml:"ct_mask"`
 }
 
-//func (t *TargetConnMark) MarshalJSON() (b []byte, e error) {
-//	type TargetConnMarkHelper struct {
-//		Type string `json:"type"`
-//		Value *TargetConnMark `json:"value"`
-//	}
-//
-//	th := TargetConnMarkHelper{
-//		Type: "connmark",
-//		Value: t,
-//	}
-//	return json.Marshal(th)
-//}
-
 func (t TargetConnMark) String() string {
-	target := ""
+	option := ""
+	value := fmt.Sprintf(" 0x%x", t.Value)
 	switch t.MarkType {
 	case ConnMarkTypeSet:
-		target = TargetConnMarkSet
-	case ConnMarkTypeSave:
-		target = TargetConnMarkSave
-	case ConnMarkTypeRestore:
-		target = TargetConnMarkRestore
+		option = TargetConnMarkSet
+		value = " " + formatMark(t.Value, t.Mask)
+	case ConnMarkTypeSetX:
+		option = TargetConnMarkSetX
+		value = fmt.Sprintf(" 0x%x/0x%x", t.Value, t.Mask)
+	case ConnMarkTypeAnd:
+		option = TargetConnMarkAnd
+	case ConnMarkTypeOr:
+		option = TargetConnMarkOr
+	case ConnMarkTypeXor:
+		option = TargetConnMarkXor
+	case ConnMarkTypeSave, ConnMarkTypeRestore:
+		option = TargetConnMarkSave
+		if t.MarkType == ConnMarkTypeRestore {
+			option = TargetConnMarkRestore
+		}
+		value = ""
+		if t.NfMask != 0 {
+			value += fmt.Sprintf(" %s 0x%x", TargetConnMarkNfMask, t.NfMask)
+		}
+		if t.CtMask != 0 {
+			value += fmt.Sprintf(" %s 0x%x", TargetConnMarkCtMask, t.CtMask)
+		}
 	}
 	return TargetJump{
-		Value: fmt.Sprintf("CONNMARK %s %d", target, t.Value),
+		Value: fmt.Sprintf("CONNMARK %s%s", option, value),
 	}.String()
 }
 
@@ -59,9 +80,73 @@ func (t TargetConnMark) Validate(rule Rule) error {
 	if rule.Table != TableMangle {
 		return fmt.Errorf("target CONNMARK is only valid on the 'mangle' table")
 	}
-	return nil
+	if t.MarkType < ConnMarkTypeSet || t.MarkType > ConnMarkTypeXor {
+		return fmt.Errorf("invalid target CONNMARK type %d", t.MarkType)
+	}
+	if t.Mask != 0 && t.MarkType != ConnMarkTypeSet && t.MarkType != ConnMarkTypeSetX {
+		return fmt.Errorf("target CONNMARK mask is only valid with %s and %s", TargetConnMarkSet, TargetConnMarkSetX)
+	}
+	if (t.NfMask != 0 || t.CtMask != 0) && t.MarkType != ConnMarkTypeSave && t.MarkType != ConnMarkTypeRestore {
+		return fmt.Errorf("target CONNMARK nfmask and ctmask are only valid with %s and %s", TargetConnMarkSave, TargetConnMarkRestore)
+	}
+	if err := validateMark("target CONNMARK", t.Value, t.Mask); err != nil {
+		return err
+	}
+	if err := validateMark("target CONNMARK nfmask", t.NfMask, 0); err != nil {
+		return err
+	}
+	return validateMark("target CONNMARK ctmask", t.CtMask, 0)
 }
 
+// Parse sets the target from a single option, rules printed by iptables pass each of the options of the target in
+// turn
 func (t *TargetConnMark) Parse(option string, value string) {
+	switch option {
+	case TargetConnMarkSave:
+		t.MarkType = ConnMarkTypeSave
+		return
+	case TargetConnMarkRestore:
+		t.MarkType = ConnMarkTypeRestore
+		return
+	case TargetConnMarkNfMask, TargetConnMarkCtMask, TargetConnMarkMask:
+		mask, _, err := parseMark(value)
+		if err != nil {
+			return
+		}
+		if mask == markMaskAll {
+			mask = 0
+		}
+		if option != TargetConnMarkCtMask {
+			t.NfMask = mask
+		}
+		if option != TargetConnMarkNfMask {
+			t.CtMask = mask
+		}
+		return
+	}
 
+	mark, mask, err := parseMark(value)
+	if option == TargetConnMarkSetX {
+		mark, mask, err = parseRawMark(value)
+		option, mark, mask = simplifyXMark(mark, mask)
+	}
+	if err != nil {
+		return
+	}
+	t.Value = mark
+	t.Mask = 0
+	switch option {
+	case TargetConnMarkSet:
+		t.MarkType = ConnMarkTypeSet
+		t.Mask = mask
+	case TargetConnMarkSetX:
+		t.MarkType = ConnMarkTypeSetX
+		t.Mask = mask
+	case TargetConnMarkAnd:
+		t.MarkType = ConnMarkTypeAnd
+	case TargetConnMarkOr:
+		t.MarkType = ConnMarkTypeOr
+	case TargetConnMarkXor:
+		t.MarkType = ConnMarkTypeXor
+	}
 }
@@ -0,0 +1,164 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TargetCTNoTrackStr   string = "--notrack"
+	TargetCTHelperStr    string = "--helper"
+	TargetCTTimeoutStr   string = "--timeout"
+	TargetCTCTEventsStr  string = "--ctevents"
+	TargetCTExpEventsStr string = "--expevents"
+	TargetCTZoneStr      string = "--zone"
+	TargetCTZoneOrigStr  string = "--zone-orig"
+	TargetCTZoneReplyStr string = "--zone-reply"
+
+	// targetCTZoneMark is the zone value that takes the zone from the packet mark
+	targetCTZoneMark = "mark"
+)
+
+// CTZoneDirection is the direction of the connection a conntrack zone applies to
+type CTZoneDirection string
+
+const (
+	CTZoneBoth  CTZoneDirection = ""
+	CTZoneOrig  CTZoneDirection = "orig"
+	CTZoneReply CTZoneDirection = "reply"
+)
+
+var (
+	// ctEvents are the conntrack events that can be enabled with --ctevents
+	ctEvents = []string{"new", "related", "destroy", "reply", "assured", "protoinfo", "helper", "mark", "natseqinfo", "secmark"}
+	// ctExpEvents are the expectation events that can be enabled with --expevents
+	ctExpEvents = []string{"new"}
+)
+
+// TargetCT sets the conntrack parameters of the packet before the connection is tracked. NoTrack disables tracking,
+// it is printed as -j NOTRACK when UseNoTrackTarget is set. Helper assigns a conntrack helper such as ftp or sip and
+// Timeout a timeout policy created with nfct. Zone places the connection in a conntrack zone for the directions in
+// ZoneDirection, ZoneMark takes the zone from the packet mark instead. CTEvents and ExpEvents limit the events
+// generated for the connection
+type TargetCT struct {
+	NoTrack          bool            `json:"no_track,omitempty" yaml:"no_track" xml:"no_track"`
+	UseNoTrackTarget bool            `json:"use_no_track_target,omitempty" yaml:"use_no_track_target" xml:"use_no_track_target"`
+	Helper           string          `json:"helper,omitempty" yaml:"helper" xml:"helper"`
+	Timeout          string          `json:"timeout,omitempty" yaml:"timeout" xml:"timeout"`
+	CTEvents         []string        `json:"ct_events,omitempty" yaml:"ct_events" xml:"ct_events"`
+	ExpEvents        []string        `json:"exp_events,omitempty" yaml:"exp_events" xml:"exp_events"`
+	Zone             int             `json:"zone,omitempty" yaml:"zone" xml:"zone"`
+	ZoneMark         bool            `json:"zone_mark,omitempty" yaml:"zone_mark" xml:"zone_mark"`
+	ZoneDirection    CTZoneDirection `json:"zone_direction,omitempty" yaml:"zone_direction" xml:"zone_direction"`
+}
+
+func (t TargetCT) String() string {
+	if t.NoTrack && t.UseNoTrackTarget {
+		return TargetJump{
+			Value: "NOTRACK",
+		}.String()
+	}
+
+	parts := make([]string, 0)
+	parts = append(parts, "CT")
+	if t.NoTrack {
+		parts = append(parts, TargetCTNoTrackStr)
+	}
+	if t.Helper != "" {
+		parts = append(parts, TargetCTHelperStr, t.Helper)
+	}
+	if t.Timeout != "" {
+		parts = append(parts, TargetCTTimeoutStr, t.Timeout)
+	}
+	if len(t.CTEvents) > 0 {
+		parts = append(parts, TargetCTCTEventsStr, strings.Join(t.CTEvents, ","))
+	}
+	if len(t.ExpEvents) > 0 {
+		parts = append(parts, TargetCTExpEventsStr, strings.Join(t.ExpEvents, ","))
+	}
+	if t.ZoneMark || t.Zone != 0 {
+		option := TargetCTZoneStr
+		if t.ZoneDirection != CTZoneBoth {
+			option = fmt.Sprintf("%s-%s", TargetCTZoneStr, t.ZoneDirection)
+		}
+		zone := strconv.Itoa(t.Zone)
+		if t.ZoneMark {
+			zone = targetCTZoneMark
+		}
+		parts = append(parts, option, zone)
+	}
+
+	return TargetJump{
+		Value: strings.Join(parts, " "),
+	}.String()
+}
+
+// Returns if the target is valid when applied with the specified rule
+func (t TargetCT) Validate(rule Rule) error {
+	// Only valid on the raw table
+	if rule.Table != TableRaw {
+		return fmt.Errorf("target CT is only valid on the 'raw' table")
+	}
+	if err := validateMatchChain("target CT", rule, ChainPreRouting, ChainOutput); err != nil {
+		return err
+	}
+	if t.UseNoTrackTarget && !t.NoTrack {
+		return fmt.Errorf("target CT use no track target requires no track")
+	}
+	if t.NoTrack && (t.Helper != "" || t.Timeout != "" || len(t.CTEvents) > 0 || len(t.ExpEvents) > 0 || t.Zone != 0 || t.ZoneMark) {
+		return fmt.Errorf("target CT no track can't be combined with other options")
+	}
+	if t.Zone < 0 || t.Zone > 65535 {
+		return fmt.Errorf("target CT zone must be between 0 and 65535")
+	}
+	if t.ZoneMark && t.Zone != 0 {
+		return fmt.Errorf("target CT can't use both a zone and the zone mark")
+	}
+	switch t.ZoneDirection {
+	case CTZoneBoth, CTZoneOrig, CTZoneReply:
+	default:
+		return fmt.Errorf("invalid target CT zone direction %s", t.ZoneDirection)
+	}
+	if err := validateCTEvents("ctevents", t.CTEvents, ctEvents); err != nil {
+		return err
+	}
+	return validateCTEvents("expevents", t.ExpEvents, ctExpEvents)
+}
+
+func (t *TargetCT) Parse(option string, value string) {
+	switch option {
+	case TargetCTNoTrackStr:
+		t.NoTrack = true
+	case TargetCTHelperStr:
+		t.Helper = value
+	case TargetCTTimeoutStr:
+		t.Timeout = value
+	case TargetCTCTEventsStr:
+		t.CTEvents = strings.Split(value, ",")
+	case TargetCTExpEventsStr:
+		t.ExpEvents = strings.Split(value, ",")
+	case TargetCTZoneStr, TargetCTZoneOrigStr, TargetCTZoneReplyStr:
+		t.ZoneDirection = CTZoneDirection(strings.TrimPrefix(strings.TrimPrefix(option, TargetCTZoneStr), "-"))
+		if value == targetCTZoneMark {
+			t.ZoneMark = true
+		} else {
+			t.Zone, _ = strconv.Atoi(value)
+		}
+	}
+}
+
+func validateCTEvents(option string, events []string, valid []string) error {
+	for _, event := range events {
+		found := false
+		for _, v := range valid {
+			if event == v {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid target CT %s event %s. expected one of %s", option, event, strings.Join(valid, ", "))
+		}
+	}
+	return nil
+}
@@ -58,7 +58,7 @@ type TargetDSCPClass struct {
 
 func (t TargetDSCPClass) String() string {
 	return TargetJump{
-		Value: fmt.Sprintf("DSCP %s %d", TargetDSCPClassStr, t.Class),
+		Value: fmt.Sprintf("DSCP %s %s", TargetDSCPClassStr, t.Class),
 	}.String()
 }
 
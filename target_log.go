@@ -0,0 +1,118 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TargetLogLevelStr       string = "--log-level"
+	TargetLogPrefixStr      string = "--log-prefix"
+	TargetLogTCPSequenceStr string = "--log-tcp-sequence"
+	TargetLogTCPOptionsStr  string = "--log-tcp-options"
+	TargetLogIPOptionsStr   string = "--log-ip-options"
+	TargetLogUidStr         string = "--log-uid"
+	TargetLogMacDecodeStr   string = "--log-macdecode"
+
+	// logPrefixMaxLength is the longest prefix the kernel accepts for the LOG target
+	logPrefixMaxLength = 29
+)
+
+var (
+	// logLevels are the syslog level names iptables accepts for --log-level, in the order of their numbers
+	logLevels = []string{"emerg", "alert", "crit", "error", "warning", "notice", "info", "debug"}
+)
+
+// TargetLog logs the packet headers to the kernel log. Level is a syslog level name or number, an empty Level uses
+// the default of warning. Prefix is limited to 29 characters
+type TargetLog struct {
+	Level       string `json:"level,omitempty" yaml:"level" xml:"level"`
+	Prefix      string `json:"prefix,omitempty" yaml:"prefix" xml:"prefix"`
+	TCPSequence bool   `json:"tcp_sequence,omitempty" yaml:"tcp_sequence" xml:"tcp_sequence"`
+	TCPOptions  bool   `json:"tcp_options,omitempty" yaml:"tcp_options" xml:"tcp_options"`
+	IPOptions   bool   `json:"ip_options,omitempty" yaml:"ip_options" xml:"ip_options"`
+	Uid         bool   `json:"uid,omitempty" yaml:"uid" xml:"uid"`
+	MacDecode   bool   `json:"mac_decode,omitempty" yaml:"mac_decode" xml:"mac_decode"`
+}
+
+func (t TargetLog) String() string {
+	parts := make([]string, 0)
+	parts = append(parts, "LOG")
+	if t.Prefix != "" {
+		parts = append(parts, TargetLogPrefixStr, saveString(t.Prefix))
+	}
+	if t.Level != "" {
+		parts = append(parts, TargetLogLevelStr, t.Level)
+	}
+	if t.TCPSequence {
+		parts = append(parts, TargetLogTCPSequenceStr)
+	}
+	if t.TCPOptions {
+		parts = append(parts, TargetLogTCPOptionsStr)
+	}
+	if t.IPOptions {
+		parts = append(parts, TargetLogIPOptionsStr)
+	}
+	if t.Uid {
+		parts = append(parts, TargetLogUidStr)
+	}
+	if t.MacDecode {
+		parts = append(parts, TargetLogMacDecodeStr)
+	}
+
+	return TargetJump{
+		Value: strings.Join(parts, " "),
+	}.String()
+}
+
+// Returns if the target is valid when applied with the specified rule
+func (t TargetLog) Validate(rule Rule) error {
+	if t.Level != "" && !validLogLevel(t.Level) {
+		return fmt.Errorf("invalid target LOG level %s. expected 0-7 or one of %s", t.Level, strings.Join(logLevels, ", "))
+	}
+	return validateLogPrefix("target LOG", t.Prefix, logPrefixMaxLength)
+}
+
+func (t *TargetLog) Parse(option string, value string) {
+	switch option {
+	case TargetLogLevelStr:
+		t.Level = value
+	case TargetLogPrefixStr:
+		t.Prefix = unquoteArg(value)
+	case TargetLogTCPSequenceStr:
+		t.TCPSequence = true
+	case TargetLogTCPOptionsStr:
+		t.TCPOptions = true
+	case TargetLogIPOptionsStr:
+		t.IPOptions = true
+	case TargetLogUidStr:
+		t.Uid = true
+	case TargetLogMacDecodeStr:
+		t.MacDecode = true
+	}
+}
+
+// validLogLevel returns true for a syslog level number or one of the level names iptables accepts
+func validLogLevel(level string) bool {
+	if n, err := strconv.Atoi(level); err == nil {
+		return n >= 0 && n < len(logLevels)
+	}
+	for _, name := range append(logLevels, "panic") {
+		if level == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLogPrefix checks that the prefix fits in the kernel's prefix buffer and is on a single line
+func validateLogPrefix(kind string, prefix string, maxLength int) error {
+	if len(prefix) > maxLength {
+		return fmt.Errorf("%s prefix '%s' is %d characters long, the maximum is %d", kind, prefix, len(prefix), maxLength)
+	}
+	if strings.ContainsAny(prefix, "\n\r") {
+		return fmt.Errorf("%s prefix can't contain newlines", kind)
+	}
+	return nil
+}
@@ -2,35 +2,109 @@ package iptables
 
 import (
 	"fmt"
-	"strconv"
-	"strings"
+)
+
+// MarkType is the operation the MARK target applies to the packet mark
+type MarkType int
+
+const (
+	MarkTypeSet MarkType = iota
+	MarkTypeSetX
+	MarkTypeAnd
+	MarkTypeOr
+	MarkTypeXor
 )
 
 const (
-	TargetMarkStr string = "--set-mark"
+	TargetMarkStr     string = "--set-mark"
+	TargetMarkSetXStr string = "--set-xmark"
+	TargetMarkAndStr  string = "--and-mark"
+	TargetMarkOrStr   string = "--or-mark"
+	TargetMarkXorStr  string = "--xor-mark"
 )
 
+// TargetMark changes the netfilter mark of the packet. Mask is only used by MarkTypeSet, where zero sets the whole
+// mark, and MarkTypeSetX where the bits in the mask are zeroed before the value is XORed in
 type TargetMark struct {
-	Value int `json:"value" yaml:"value" xml:"value"`
+	MarkType MarkType `json:"mark_type,omitempty" yaml:"mark_type" xml:"mark_type"`
+	Value    int      `json:"value" yaml:"value" xml:"value"`
+	Mask     int      `json:"mask,omitempty" yaml:"mask" xml:"mask"`
 }
 
 func (t TargetMark) String() string {
+	option := ""
+	value := fmt.Sprintf("0x%x", t.Value)
+	switch t.MarkType {
+	case MarkTypeSet:
+		option = TargetMarkStr
+		value = formatMark(t.Value, t.Mask)
+	case MarkTypeSetX:
+		option = TargetMarkSetXStr
+		value = fmt.Sprintf("0x%x/0x%x", t.Value, t.Mask)
+	case MarkTypeAnd:
+		option = TargetMarkAndStr
+	case MarkTypeOr:
+		option = TargetMarkOrStr
+	case MarkTypeXor:
+		option = TargetMarkXorStr
+	}
 	return TargetJump{
-		Value: fmt.Sprintf("MARK %s %d", TargetMarkStr, t.Value),
+		Value: fmt.Sprintf("MARK %s %s", option, value),
 	}.String()
 }
 
 // Returns if the target is valid when applied with the specified rule
 func (t TargetMark) Validate(rule Rule) error {
-	// No validation needed?
-	return nil
+	if t.MarkType < MarkTypeSet || t.MarkType > MarkTypeXor {
+		return fmt.Errorf("invalid target MARK type %d", t.MarkType)
+	}
+	if t.Mask != 0 && t.MarkType != MarkTypeSet && t.MarkType != MarkTypeSetX {
+		return fmt.Errorf("target MARK mask is only valid with %s and %s", TargetMarkStr, TargetMarkSetXStr)
+	}
+	return validateMark("target MARK", t.Value, t.Mask)
 }
 
 func (t *TargetMark) Parse(option string, value string) {
-	if option == "--set-mask" {
-		value = strings.Replace(value, "0x", "", -1)
-		value = strings.Replace(value, "0X", "", -1)
-		v, _ := strconv.ParseInt(value, 16, 64)
-		t.Value = int(v)
+	mark, mask, err := parseMark(value)
+	if option == TargetMarkSetXStr {
+		mark, mask, err = parseRawMark(value)
+		option, mark, mask = simplifyXMark(mark, mask)
+	}
+	if err != nil {
+		return
+	}
+	t.Value = mark
+	t.Mask = 0
+	switch option {
+	case TargetMarkStr:
+		t.MarkType = MarkTypeSet
+		t.Mask = mask
+	case TargetMarkSetXStr:
+		t.MarkType = MarkTypeSetX
+		t.Mask = mask
+	case TargetMarkAndStr:
+		t.MarkType = MarkTypeAnd
+	case TargetMarkOrStr:
+		t.MarkType = MarkTypeOr
+	case TargetMarkXorStr:
+		t.MarkType = MarkTypeXor
+	}
+}
+
+// simplifyXMark returns the simplest option that is equivalent to --set-xmark mark/mask. iptables prints every
+// --set-mark, --and-mark, --or-mark and --xor-mark as --set-xmark so this recovers the original option when parsing
+func simplifyXMark(mark int, mask int) (option string, value int, newMask int) {
+	switch {
+	case mask == markMaskAll:
+		return TargetMarkStr, mark, 0
+	case mark == 0:
+		return TargetMarkAndStr, ^mask & markMaskAll, 0
+	case mask == 0:
+		return TargetMarkXorStr, mark, 0
+	case mark == mask:
+		return TargetMarkOrStr, mark, 0
+	case mark&^mask == 0:
+		return TargetMarkStr, mark, mask
 	}
+	return TargetMarkSetXStr, mark, mask
 }
@@ -0,0 +1,79 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TargetNFLogGroupStr     string = "--nflog-group"
+	TargetNFLogPrefixStr    string = "--nflog-prefix"
+	TargetNFLogSizeStr      string = "--nflog-size"
+	TargetNFLogRangeStr     string = "--nflog-range"
+	TargetNFLogThresholdStr string = "--nflog-threshold"
+
+	// nflogPrefixMaxLength is the longest prefix the kernel accepts for the NFLOG target
+	nflogPrefixMaxLength = 63
+)
+
+// TargetNFLog passes the packet to the userspace logger listening on the netlink group. Size limits the number of
+// bytes of the packet that are copied, nil copies the whole packet. Threshold is the number of packets queued in the
+// kernel before they are sent, zero leaves the default of 1
+type TargetNFLog struct {
+	Group     int     `json:"group,omitempty" yaml:"group" xml:"group"`
+	Prefix    string  `json:"prefix,omitempty" yaml:"prefix" xml:"prefix"`
+	Size      *uint32 `json:"size,omitempty" yaml:"size" xml:"size"`
+	Threshold int     `json:"threshold,omitempty" yaml:"threshold" xml:"threshold"`
+}
+
+func (t TargetNFLog) String() string {
+	parts := make([]string, 0)
+	parts = append(parts, "NFLOG")
+	if t.Prefix != "" {
+		parts = append(parts, TargetNFLogPrefixStr, saveString(t.Prefix))
+	}
+	if t.Group != 0 {
+		parts = append(parts, TargetNFLogGroupStr, strconv.Itoa(t.Group))
+	}
+	if t.Size != nil {
+		parts = append(parts, TargetNFLogSizeStr, strconv.FormatUint(uint64(*t.Size), 10))
+	}
+	if t.Threshold != 0 {
+		parts = append(parts, TargetNFLogThresholdStr, strconv.Itoa(t.Threshold))
+	}
+
+	return TargetJump{
+		Value: strings.Join(parts, " "),
+	}.String()
+}
+
+// Returns if the target is valid when applied with the specified rule
+func (t TargetNFLog) Validate(rule Rule) error {
+	if t.Group < 0 || t.Group > 65535 {
+		return fmt.Errorf("target NFLOG group must be between 0 and 65535")
+	}
+	if t.Threshold < 0 {
+		return fmt.Errorf("target NFLOG threshold must be at least 1")
+	}
+	return validateLogPrefix("target NFLOG", t.Prefix, nflogPrefixMaxLength)
+}
+
+func (t *TargetNFLog) Parse(option string, value string) {
+	switch option {
+	case TargetNFLogGroupStr:
+		t.Group, _ = strconv.Atoi(value)
+	case TargetNFLogPrefixStr:
+		t.Prefix = unquoteArg(value)
+	case TargetNFLogSizeStr, TargetNFLogRangeStr:
+		if size, err := strconv.ParseUint(value, 10, 32); err == nil {
+			s := uint32(size)
+			t.Size = &s
+		}
+	case TargetNFLogThresholdStr:
+		t.Threshold, _ = strconv.Atoi(value)
+		if t.Threshold == 1 {
+			t.Threshold = 0
+		}
+	}
+}
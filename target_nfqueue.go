@@ -0,0 +1,75 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TargetNFQueueNumStr       string = "--queue-num"
+	TargetNFQueueBalanceStr   string = "--queue-balance"
+	TargetNFQueueBypassStr    string = "--queue-bypass"
+	TargetNFQueueCPUFanoutStr string = "--queue-cpu-fanout"
+)
+
+// TargetNFQueue passes the packet to the userspace program bound to the queue, which decides the verdict. When
+// BalanceLast is set packets are spread over the queues QueueNum to BalanceLast, by flow or by cpu when CPUFanout is
+// set. Bypass accepts packets instead of dropping them when no program is bound to the queue
+type TargetNFQueue struct {
+	QueueNum    int  `json:"queue_num,omitempty" yaml:"queue_num" xml:"queue_num"`
+	BalanceLast int  `json:"balance_last,omitempty" yaml:"balance_last" xml:"balance_last"`
+	Bypass      bool `json:"bypass,omitempty" yaml:"bypass" xml:"bypass"`
+	CPUFanout   bool `json:"cpu_fanout,omitempty" yaml:"cpu_fanout" xml:"cpu_fanout"`
+}
+
+func (t TargetNFQueue) String() string {
+	parts := make([]string, 0)
+	parts = append(parts, "NFQUEUE")
+	if t.BalanceLast != 0 {
+		parts = append(parts, TargetNFQueueBalanceStr, fmt.Sprintf("%d:%d", t.QueueNum, t.BalanceLast))
+	} else {
+		parts = append(parts, TargetNFQueueNumStr, strconv.Itoa(t.QueueNum))
+	}
+	if t.Bypass {
+		parts = append(parts, TargetNFQueueBypassStr)
+	}
+	if t.CPUFanout {
+		parts = append(parts, TargetNFQueueCPUFanoutStr)
+	}
+
+	return TargetJump{
+		Value: strings.Join(parts, " "),
+	}.String()
+}
+
+// Returns if the target is valid when applied with the specified rule
+func (t TargetNFQueue) Validate(rule Rule) error {
+	if t.QueueNum < 0 || t.QueueNum > 65535 || t.BalanceLast < 0 || t.BalanceLast > 65535 {
+		return fmt.Errorf("target NFQUEUE queue numbers must be between 0 and 65535")
+	}
+	if t.BalanceLast != 0 && t.BalanceLast <= t.QueueNum {
+		return fmt.Errorf("target NFQUEUE balance range %d:%d must end after it starts", t.QueueNum, t.BalanceLast)
+	}
+	if t.CPUFanout && t.BalanceLast == 0 {
+		return fmt.Errorf("target NFQUEUE cpu fanout requires a balance range")
+	}
+	return nil
+}
+
+func (t *TargetNFQueue) Parse(option string, value string) {
+	switch option {
+	case TargetNFQueueNumStr:
+		t.QueueNum, _ = strconv.Atoi(value)
+	case TargetNFQueueBalanceStr:
+		parts := strings.SplitN(value, ":", 2)
+		t.QueueNum, _ = strconv.Atoi(parts[0])
+		if len(parts) == 2 {
+			t.BalanceLast, _ = strconv.Atoi(parts[1])
+		}
+	case TargetNFQueueBypassStr:
+		t.Bypass = true
+	case TargetNFQueueCPUFanoutStr:
+		t.CPUFanout = true
+	}
+}
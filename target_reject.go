@@ -1,6 +1,7 @@
 package iptables
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -8,6 +9,33 @@ const (
 	TargetRejectStr string = "--reject-with"
 )
 
+var (
+	// rejectTypes are the reject types iptables accepts, including the short aliases
+	rejectTypes = []string{
+		"icmp-net-unreachable", "net-unreach",
+		"icmp-host-unreachable", "host-unreach",
+		"icmp-port-unreachable", "port-unreach",
+		"icmp-proto-unreachable", "proto-unreach",
+		"icmp-net-prohibited", "net-prohib",
+		"icmp-host-prohibited", "host-prohib",
+		"icmp-admin-prohibited", "admin-prohib",
+		"tcp-reset", "tcp-rst",
+	}
+
+	// reject6Types are the reject types ip6tables accepts, including the short aliases
+	reject6Types = []string{
+		"icmp6-no-route", "no-route",
+		"icmp6-adm-prohibited", "adm-prohibited",
+		"icmp6-addr-unreachable", "addr-unreach",
+		"icmp6-port-unreachable", "port-unreach",
+		"icmp6-policy-fail", "policy-fail",
+		"icmp6-reject-route", "reject-route",
+		"tcp-reset",
+	}
+)
+
+// TargetReject drops the packet and sends an error back. An empty RejectType uses the port unreachable error of
+// the rule's ip version
 type TargetReject struct {
 	RejectType string `json:"reject_type" yaml:"reject_type" xml:"reject_type"`
 }
@@ -17,8 +45,6 @@ func (t TargetReject) String() string {
 	parts = append(parts, "REJECT")
 	if t.RejectType != "" {
 		parts = append(parts, TargetRejectStr, t.RejectType)
-	} else {
-		parts = append(parts, TargetRejectStr, "--icmp-port-unreachable")
 	}
 
 	return TargetJump{
@@ -26,12 +52,32 @@ func (t TargetReject) String() string {
 	}.String()
 }
 
+// Returns if the target is valid when applied with the specified rule
 func (t TargetReject) Validate(rule Rule) error {
+	if t.RejectType == "" {
+		return nil
+	}
+	ver, valid := IPv4, rejectTypes
+	if rule.IpVersion == IPv6 {
+		ver, valid = IPv6, reject6Types
+	}
+	found := false
+	for _, rejectType := range valid {
+		if t.RejectType == rejectType {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("target REJECT type %s is not valid for %s rules", t.RejectType, ver)
+	}
+	if (t.RejectType == "tcp-reset" || t.RejectType == "tcp-rst") && (rule.Protocol != ProtocolTCP || rule.ProtocolNegated) {
+		return fmt.Errorf("target REJECT type %s requires the protocol to be %s", t.RejectType, ProtocolTCP)
+	}
 	return nil
 }
 
 func (t *TargetReject) Parse(option string, value string) {
-	if option == "--reject-with" {
+	if option == TargetRejectStr {
 		t.RejectType = value
 	}
 }
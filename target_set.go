@@ -0,0 +1,107 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TargetSetAddStr     string = "--add-set"
+	TargetSetDelStr     string = "--del-set"
+	TargetSetTimeoutStr string = "--timeout"
+	TargetSetExistStr   string = "--exist"
+)
+
+// TargetSet adds the packet to and/or deletes the packet from ipset sets. Timeout and Exist only apply when adding
+type TargetSet struct {
+	AddSet        string         `json:"add_set,omitempty" yaml:"add_set" xml:"add_set"`
+	AddDirections []SetDirection `json:"add_directions,omitempty" yaml:"add_directions" xml:"add_directions"`
+	DelSet        string         `json:"del_set,omitempty" yaml:"del_set" xml:"del_set"`
+	DelDirections []SetDirection `json:"del_directions,omitempty" yaml:"del_directions" xml:"del_directions"`
+	Timeout       int            `json:"timeout,omitempty" yaml:"timeout" xml:"timeout"`
+	Exist         bool           `json:"exist,omitempty" yaml:"exist" xml:"exist"`
+}
+
+func (t TargetSet) String() string {
+	parts := make([]string, 0)
+	parts = append(parts, "SET")
+	if t.AddSet != "" {
+		parts = append(parts, TargetSetAddStr, t.AddSet, joinSetDirections(t.AddDirections))
+	}
+	if t.DelSet != "" {
+		parts = append(parts, TargetSetDelStr, t.DelSet, joinSetDirections(t.DelDirections))
+	}
+	if t.Timeout > 0 {
+		parts = append(parts, TargetSetTimeoutStr, strconv.Itoa(t.Timeout))
+	}
+	if t.Exist {
+		parts = append(parts, TargetSetExistStr)
+	}
+
+	return TargetJump{
+		Value: strings.Join(parts, " "),
+	}.String()
+}
+
+// Returns if the target is valid when applied with the specified rule
+func (t TargetSet) Validate(rule Rule) error {
+	if t.AddSet == "" && t.DelSet == "" {
+		return fmt.Errorf("target SET requires a set to add to or delete from")
+	}
+	if t.AddSet != "" {
+		if err := validateSetReference("target SET", t.AddSet, t.AddDirections); err != nil {
+			return err
+		}
+	}
+	if t.DelSet != "" {
+		if err := validateSetReference("target SET", t.DelSet, t.DelDirections); err != nil {
+			return err
+		}
+	}
+	if (t.Timeout != 0 || t.Exist) && t.AddSet == "" {
+		return fmt.Errorf("target SET timeout and exist are only valid when adding to a set")
+	}
+	if t.Timeout < 0 {
+		return fmt.Errorf("target SET timeout can't be negative")
+	}
+	return nil
+}
+
+func (t *TargetSet) Parse(option string, value string) {
+	switch option {
+	case TargetSetAddStr, TargetSetDelStr:
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return
+		}
+		directions := splitSetDirections(fields[1])
+		if option == TargetSetAddStr {
+			t.AddSet = fields[0]
+			t.AddDirections = directions
+		} else {
+			t.DelSet = fields[0]
+			t.DelDirections = directions
+		}
+	case TargetSetTimeoutStr:
+		t.Timeout, _ = strconv.Atoi(value)
+	case TargetSetExistStr:
+		t.Exist = true
+	}
+}
+
+func joinSetDirections(directions []SetDirection) string {
+	parts := make([]string, 0)
+	for _, direction := range directions {
+		parts = append(parts, string(direction))
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitSetDirections(value string) []SetDirection {
+	directions := make([]SetDirection, 0)
+	for _, direction := range strings.Split(value, ",") {
+		directions = append(directions, SetDirection(direction))
+	}
+	return directions
+}
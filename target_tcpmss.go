@@ -0,0 +1,71 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TargetTCPMSSSetStr   string = "--set-mss"
+	TargetTCPMSSClampStr string = "--clamp-mss-to-pmtu"
+)
+
+// TargetTCPMSS rewrites the MSS option of TCP SYN packets, either to SetMss or to the path MTU minus the headers
+// when ClampMssToPmtu is set. The rule must only match TCP SYN packets
+type TargetTCPMSS struct {
+	SetMss         int  `json:"set_mss,omitempty" yaml:"set_mss" xml:"set_mss"`
+	ClampMssToPmtu bool `json:"clamp_mss_to_pmtu,omitempty" yaml:"clamp_mss_to_pmtu" xml:"clamp_mss_to_pmtu"`
+}
+
+func (t TargetTCPMSS) String() string {
+	parts := make([]string, 0)
+	parts = append(parts, "TCPMSS")
+	if t.ClampMssToPmtu {
+		parts = append(parts, TargetTCPMSSClampStr)
+	} else {
+		parts = append(parts, TargetTCPMSSSetStr, strconv.Itoa(t.SetMss))
+	}
+
+	return TargetJump{
+		Value: strings.Join(parts, " "),
+	}.String()
+}
+
+// Returns if the target is valid when applied with the specified rule
+func (t TargetTCPMSS) Validate(rule Rule) error {
+	if rule.Table != TableMangle && rule.Table != TableFilter {
+		return fmt.Errorf("target TCPMSS is only valid on the 'mangle' and 'filter' tables")
+	}
+	if t.ClampMssToPmtu == (t.SetMss != 0) {
+		return fmt.Errorf("target TCPMSS requires exactly one of set mss or clamp mss to pmtu")
+	}
+	if t.SetMss < 0 || t.SetMss > 65535 {
+		return fmt.Errorf("target TCPMSS mss must be between 0 and 65535")
+	}
+	if rule.Protocol != ProtocolTCP || rule.ProtocolNegated {
+		return fmt.Errorf("target TCPMSS requires the protocol to be %s", ProtocolTCP)
+	}
+	syn := false
+	for _, match := range rule.Matches {
+		if tcp, ok := match.(*MatchTCP); ok && tcp.IsSyn() {
+			syn = true
+		}
+	}
+	if !syn {
+		return fmt.Errorf("target TCPMSS requires a tcp match that only matches SYN packets, for example --tcp-flags SYN,RST SYN")
+	}
+	if t.ClampMssToPmtu {
+		return validateMatchChain("target TCPMSS clamp mss to pmtu", rule, ChainForward, ChainOutput, ChainPostRouting)
+	}
+	return nil
+}
+
+func (t *TargetTCPMSS) Parse(option string, value string) {
+	switch option {
+	case TargetTCPMSSSetStr:
+		t.SetMss, _ = strconv.Atoi(value)
+	case TargetTCPMSSClampStr:
+		t.ClampMssToPmtu = true
+	}
+}
@@ -0,0 +1,77 @@
+package iptables
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	TargetTProxyOnPortStr string = "--on-port"
+	TargetTProxyOnIpStr   string = "--on-ip"
+	TargetTProxyMarkStr   string = "--tproxy-mark"
+)
+
+// TargetTProxy redirects the packet to a local socket without changing its destination. OnPort and OnIp select the
+// socket, zero and empty keep the original destination port and address. The packet is marked with Mark and Mask
+// so that a policy routing rule can deliver it locally, a zero Mask sets the whole mark
+type TargetTProxy struct {
+	OnPort int    `json:"on_port" yaml:"on_port" xml:"on_port"`
+	OnIp   string `json:"on_ip,omitempty" yaml:"on_ip" xml:"on_ip"`
+	Mark   int    `json:"mark,omitempty" yaml:"mark" xml:"mark"`
+	Mask   int    `json:"mask,omitempty" yaml:"mask" xml:"mask"`
+}
+
+func (t TargetTProxy) String() string {
+	parts := make([]string, 0)
+	parts = append(parts, "TPROXY", TargetTProxyOnPortStr, strconv.Itoa(t.OnPort))
+	if t.OnIp != "" {
+		parts = append(parts, TargetTProxyOnIpStr, t.OnIp)
+	}
+	if t.Mark != 0 || t.Mask != 0 {
+		parts = append(parts, TargetTProxyMarkStr, formatMark(t.Mark, t.Mask))
+	}
+
+	return TargetJump{
+		Value: strings.Join(parts, " "),
+	}.String()
+}
+
+// Returns if the target is valid when applied with the specified rule
+func (t TargetTProxy) Validate(rule Rule) error {
+	// Only valid on the mangle table
+	if rule.Table != TableMangle {
+		return fmt.Errorf("target TPROXY is only valid on the 'mangle' table")
+	}
+	if err := validateMatchChain("target TPROXY", rule, ChainPreRouting); err != nil {
+		return err
+	}
+	if (rule.Protocol != ProtocolTCP && rule.Protocol != ProtocolUDP) || rule.ProtocolNegated {
+		return fmt.Errorf("target TPROXY requires the protocol to be %s or %s", ProtocolTCP, ProtocolUDP)
+	}
+	if t.OnPort < 0 || t.OnPort > 65535 {
+		return fmt.Errorf("target TPROXY port must be between 0 and 65535")
+	}
+	if t.OnIp != "" {
+		ip := net.ParseIP(t.OnIp)
+		if ip == nil {
+			return fmt.Errorf("invalid target TPROXY address %s", t.OnIp)
+		}
+		if err := validateAddressFamily("target TPROXY", ip, rule.IpVersion); err != nil {
+			return err
+		}
+	}
+	return validateMark("target TPROXY mark", t.Mark, t.Mask)
+}
+
+func (t *TargetTProxy) Parse(option string, value string) {
+	switch option {
+	case TargetTProxyOnPortStr:
+		t.OnPort, _ = strconv.Atoi(value)
+	case TargetTProxyOnIpStr:
+		t.OnIp = value
+	case TargetTProxyMarkStr:
+		t.Mark, t.Mask, _ = parseMark(value)
+	}
+}
@@ -0,0 +1,246 @@
+package iptables
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	tproxyDefaultMark       = 0x1
+	tproxyDefaultRouteTable = 100
+	tproxyChainPrefix       = "DIVERT-"
+	// tproxyMaxChainLength is the longest chain name iptables accepts
+	tproxyMaxChainLength = 28
+)
+
+// TProxy describes a complete transparent proxy setup. Install creates a mangle chain that marks and accepts the
+// packets of connections that already have a transparent socket, a PREROUTING rule per protocol that sends those
+// packets to the chain, a PREROUTING TPROXY rule per protocol that diverts new connections to OnPort, and an ip rule
+// and local route that deliver the marked packets to the local stack. Remove deletes all of it again.
+//
+// Input, Destination and DestinationPort limit the traffic that is diverted. Mark and Mask default to 0x1 and
+// RouteTable to 100, Protocols defaults to tcp
+type TProxy struct {
+	Name            string     `json:"name" yaml:"name" xml:"name"`
+	IpVersion       IPVer      `json:"ip_version,omitempty" yaml:"ip_version" xml:"ip_version"`
+	Protocols       []Protocol `json:"protocols,omitempty" yaml:"protocols" xml:"protocols"`
+	Input           string     `json:"input,omitempty" yaml:"input" xml:"input"`
+	Destination     string     `json:"destination,omitempty" yaml:"destination" xml:"destination"`
+	DestinationPort string     `json:"destination_port,omitempty" yaml:"destination_port" xml:"destination_port"`
+	OnPort          int        `json:"on_port" yaml:"on_port" xml:"on_port"`
+	OnIp            string     `json:"on_ip,omitempty" yaml:"on_ip" xml:"on_ip"`
+	Mark            int        `json:"mark,omitempty" yaml:"mark" xml:"mark"`
+	Mask            int        `json:"mask,omitempty" yaml:"mask" xml:"mask"`
+	RouteTable      int        `json:"route_table,omitempty" yaml:"route_table" xml:"route_table"`
+}
+
+// Validate checks that the setup can be installed, the rules themselves are validated when they are committed
+func (p TProxy) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("tproxy requires a name")
+	}
+	if len(p.chain()) > tproxyMaxChainLength {
+		return fmt.Errorf("tproxy name %s is too long, the chain name %s is longer than %d characters", p.Name, p.chain(), tproxyMaxChainLength)
+	}
+	if strings.ContainsAny(p.Name, " \t\"'") {
+		return fmt.Errorf("tproxy name %s can't contain whitespace or quotes", p.Name)
+	}
+	if p.ipVersion() != IPv4 && p.ipVersion() != IPv6 {
+		return fmt.Errorf("invalid tproxy ip version %s", p.IpVersion)
+	}
+	if p.RouteTable < 0 {
+		return fmt.Errorf("tproxy route table must be positive")
+	}
+	for _, protocol := range p.protocols() {
+		if protocol != ProtocolTCP && protocol != ProtocolUDP {
+			return fmt.Errorf("tproxy protocol must be %s or %s", ProtocolTCP, ProtocolUDP)
+		}
+	}
+	return nil
+}
+
+// Install adds the iptables rules in a single transaction and then adds the ip rule and route. If the routing
+// entries can't be added the iptables rules are removed again
+func (p TProxy) Install() error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	t := NewTransaction()
+	t.NewChain(p.ipVersion(), TableMangle, p.chain())
+	for _, rule := range p.rules() {
+		t.Append(rule)
+	}
+	if err := t.Commit(); err != nil {
+		return fmt.Errorf("failed to add the tproxy rules: %w", err)
+	}
+
+	routing := p.routing()
+	for idx, args := range routing {
+		result, err := run(fmt.Sprintf("ip -%s %s add %s", p.family(), args[0], args[1]))
+		if err != nil {
+			err = fmt.Errorf("failed to add the tproxy %s: %w: %s", args[0], err, strings.TrimSpace(result))
+			// Only the entries added by this call are removed, the failed entry may belong to something else
+			errs := append(p.removeRouting(routing[:idx]), p.removeRules()...)
+			if len(errs) > 0 {
+				return fmt.Errorf("%v, the rollback also failed: %s", err, strings.Join(errs, ", "))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove deletes the routing entries and the iptables rules of the setup. Entries that don't exist are skipped so
+// that a partially installed setup can be removed
+func (p TProxy) Remove() error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	errs := append(p.removeRouting(p.routing()), p.removeRules()...)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// removeRouting deletes the routing entries, entries that don't exist are skipped
+func (p TProxy) removeRouting(routing [][2]string) (errs []string) {
+	errs = make([]string, 0)
+	for _, args := range routing {
+		result, err := run(fmt.Sprintf("ip -%s %s del %s", p.family(), args[0], args[1]))
+		if err != nil && !strings.Contains(result, "No such") {
+			errs = append(errs, fmt.Sprintf("failed to delete the tproxy %s: %v: %s", args[0], err, strings.TrimSpace(result)))
+		}
+	}
+	return errs
+}
+
+// removeRules deletes the iptables rules and the chain in a single transaction, rules that don't exist are skipped
+// so that removing a setup that isn't installed succeeds
+func (p TProxy) removeRules() (errs []string) {
+	errs = make([]string, 0)
+	chainExists := validChain(p.ipVersion(), string(TableMangle), string(p.chain()))
+	t := NewTransaction()
+	for _, rule := range p.rules() {
+		if rule.Chain == p.chain() {
+			// The rules in the chain are removed when it is flushed
+			continue
+		}
+		if jump, ok := rule.Target.(*TargetJump); ok && jump.Value == string(p.chain()) && !chainExists {
+			// Rules that jump to the chain can't exist without it and iptables can't check for them
+			continue
+		}
+		exists, err := rule.Exists()
+		if err != nil && !missingRuleTarget(err) {
+			errs = append(errs, err.Error())
+		} else if exists {
+			t.Delete(rule)
+		}
+	}
+	if chainExists {
+		t.FlushChain(p.ipVersion(), TableMangle, p.chain())
+		t.DeleteChain(p.ipVersion(), TableMangle, p.chain())
+	}
+	if t.Len() > 0 {
+		if err := t.Commit(); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to delete the tproxy rules: %v", err))
+		}
+	}
+	return errs
+}
+
+// missingRuleTarget reports whether a rule check failed because the target or chain of the rule doesn't exist, in
+// which case the rule can't exist either
+func missingRuleTarget(err error) bool {
+	return strings.Contains(err.Error(), "Couldn't load target") || strings.Contains(err.Error(), "does not exist")
+}
+
+// rules returns the rules of the setup in the order they are appended
+func (p TProxy) rules() []*Rule {
+	mark, mask := p.mark()
+	rules := make([]*Rule, 0)
+
+	markRule := p.rule("mark", p.chain(), ProtocolInvalid)
+	markRule.Target = &TargetMark{MarkType: MarkTypeSet, Value: mark, Mask: mask}
+	acceptRule := p.rule("accept", p.chain(), ProtocolInvalid)
+	acceptRule.Target = &TargetJump{Value: "ACCEPT"}
+	rules = append(rules, markRule, acceptRule)
+
+	for _, protocol := range p.protocols() {
+		divert := p.rule(fmt.Sprintf("divert-%s", protocol), ChainPreRouting, protocol)
+		divert.AddMatch(&MatchSocket{Transparent: true})
+		divert.Target = &TargetJump{Value: string(p.chain())}
+		rules = append(rules, divert)
+	}
+
+	for _, protocol := range p.protocols() {
+		proxy := p.rule(fmt.Sprintf("proxy-%s", protocol), ChainPreRouting, protocol)
+		proxy.Input = p.Input
+		proxy.Destination = p.Destination
+		proxy.DestinationPort = p.DestinationPort
+		proxy.Target = &TargetTProxy{OnPort: p.OnPort, OnIp: p.OnIp, Mark: mark, Mask: mask}
+		rules = append(rules, proxy)
+	}
+	return rules
+}
+
+// rule returns a mangle rule with an id made from the name of the setup and the part of the setup it implements
+func (p TProxy) rule(part string, chain Chain, protocol Protocol) *Rule {
+	rule := NewRule(fmt.Sprintf("tproxy-%s-%s", p.Name, part), nil)
+	rule.IpVersion = p.ipVersion()
+	rule.Table = TableMangle
+	rule.Chain = chain
+	rule.Protocol = protocol
+	return rule
+}
+
+// routing returns the ip rule and ip route entries of the setup as the object and its arguments
+func (p TProxy) routing() [][2]string {
+	mark, mask := p.mark()
+	table := p.RouteTable
+	if table == 0 {
+		table = tproxyDefaultRouteTable
+	}
+	local := "0.0.0.0/0"
+	if p.ipVersion() == IPv6 {
+		local = "::/0"
+	}
+	return [][2]string{
+		{"rule", fmt.Sprintf("fwmark %s lookup %d", formatMark(mark, mask), table)},
+		{"route", fmt.Sprintf("local %s dev lo table %d", local, table)},
+	}
+}
+
+func (p TProxy) chain() Chain {
+	return Chain(tproxyChainPrefix + p.Name)
+}
+
+func (p TProxy) mark() (mark int, mask int) {
+	if p.Mark == 0 && p.Mask == 0 {
+		return tproxyDefaultMark, tproxyDefaultMark
+	}
+	return p.Mark, p.Mask
+}
+
+func (p TProxy) protocols() []Protocol {
+	if len(p.Protocols) == 0 {
+		return []Protocol{ProtocolTCP}
+	}
+	return p.Protocols
+}
+
+func (p TProxy) ipVersion() IPVer {
+	if p.IpVersion == "" {
+		return IPv4
+	}
+	return p.IpVersion
+}
+
+func (p TProxy) family() string {
+	if p.ipVersion() == IPv6 {
+		return "6"
+	}
+	return "4"
+}
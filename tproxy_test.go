@@ -0,0 +1,169 @@
+package iptables
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	tproxyTestInstall = "*mangle\n" +
+		"--new-chain DIVERT-web\n" +
+		"--append DIVERT-web -m comment --comment \"id:tproxy-web-mark\" --jump MARK --set-mark 0x1/0x1\n" +
+		"--append DIVERT-web -m comment --comment \"id:tproxy-web-accept\" --jump ACCEPT\n" +
+		"--append PREROUTING --protocol tcp --match socket --transparent -m comment --comment \"id:tproxy-web-divert-tcp\" --jump DIVERT-web\n" +
+		"--append PREROUTING --protocol tcp -m comment --comment \"id:tproxy-web-proxy-tcp\" --jump TPROXY --on-port 8080 --tproxy-mark 0x1/0x1\n" +
+		"COMMIT\n"
+	tproxyTestDivert = "iptables -t mangle --check PREROUTING --protocol tcp --match socket --transparent -m comment --comment \"id:tproxy-web-divert-tcp\" --jump DIVERT-web"
+	tproxyTestProxy  = "iptables -t mangle --check PREROUTING --protocol tcp -m comment --comment \"id:tproxy-web-proxy-tcp\" --jump TPROXY --on-port 8080 --tproxy-mark 0x1/0x1"
+	tproxyTestChains = "Chain PREROUTING (policy ACCEPT 0 packets, 0 bytes)\n" +
+		"num   pkts bytes target     prot opt in     out     source               destination\n" +
+		"1        0     0 DIVERT-web  tcp  --  *      *       0.0.0.0/0            0.0.0.0/0\n" +
+		"\n" +
+		"Chain DIVERT-web (1 references)\n"
+)
+
+func TestTProxyInstall(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []ExecRecord
+		want    []ExecRecord
+		wantErr string
+	}{
+		{
+			name: "installed",
+			want: []ExecRecord{
+				{Command: "iptables-restore --noflush", Input: tproxyTestInstall},
+				{Command: "ip -4 rule add fwmark 0x1/0x1 lookup 100"},
+				{Command: "ip -4 route add local 0.0.0.0/0 dev lo table 100"},
+			},
+		},
+		{
+			name: "rules rejected",
+			records: []ExecRecord{
+				{Command: "iptables-restore --noflush", Output: "iptables-restore: line 5 failed", Error: "exit status 1"},
+			},
+			want: []ExecRecord{
+				{Command: "iptables-restore --noflush", Input: tproxyTestInstall},
+			},
+			wantErr: "failed to add the tproxy rules: ipv4 transaction failed at line 5",
+		},
+		{
+			name: "route rolled back",
+			records: []ExecRecord{
+				{Command: "ip -4 route add local 0.0.0.0/0 dev lo table 100", Output: "RTNETLINK answers: File exists", Error: "exit status 2"},
+				{Command: "iptables -t mangle -vnL --line-numbers", Output: tproxyTestChains},
+			},
+			want: []ExecRecord{
+				{Command: "iptables-restore --noflush", Input: tproxyTestInstall},
+				{Command: "ip -4 rule add fwmark 0x1/0x1 lookup 100"},
+				{Command: "ip -4 route add local 0.0.0.0/0 dev lo table 100"},
+				// Only the rule added by this call is removed, the route that failed belongs to something else
+				{Command: "ip -4 rule del fwmark 0x1/0x1 lookup 100"},
+				{Command: "iptables -t mangle -vnL --line-numbers"},
+				{Command: tproxyTestDivert},
+				{Command: tproxyTestProxy},
+				{Command: "iptables-restore --noflush", Input: "*mangle\n" +
+					"--delete PREROUTING --protocol tcp --match socket --transparent -m comment --comment \"id:tproxy-web-divert-tcp\" --jump DIVERT-web\n" +
+					"--delete PREROUTING --protocol tcp -m comment --comment \"id:tproxy-web-proxy-tcp\" --jump TPROXY --on-port 8080 --tproxy-mark 0x1/0x1\n" +
+					"--flush DIVERT-web\n" +
+					"--delete-chain DIVERT-web\n" +
+					"COMMIT\n"},
+			},
+			wantErr: "failed to add the tproxy route: exit status 2: RTNETLINK answers: File exists",
+		},
+		{
+			name: "rollback failed",
+			records: []ExecRecord{
+				{Command: "ip -4 rule add fwmark 0x1/0x1 lookup 100", Output: "RTNETLINK answers: Operation not permitted", Error: "exit status 2"},
+				{Command: "iptables -t mangle -vnL --line-numbers", Output: tproxyTestChains},
+				{Command: tproxyTestProxy, Output: "iptables: Bad rule (does a matching rule exist in that chain?).", Error: "exit status 1"},
+				{Command: "iptables-restore --noflush"},
+				{Command: "iptables-restore --noflush", Output: "iptables-restore: line 3 failed", Error: "exit status 1"},
+			},
+			want: []ExecRecord{
+				{Command: "iptables-restore --noflush", Input: tproxyTestInstall},
+				{Command: "ip -4 rule add fwmark 0x1/0x1 lookup 100"},
+				{Command: "iptables -t mangle -vnL --line-numbers"},
+				{Command: tproxyTestDivert},
+				{Command: tproxyTestProxy},
+				{Command: "iptables-restore --noflush", Input: "*mangle\n" +
+					"--delete PREROUTING --protocol tcp --match socket --transparent -m comment --comment \"id:tproxy-web-divert-tcp\" --jump DIVERT-web\n" +
+					"--flush DIVERT-web\n" +
+					"--delete-chain DIVERT-web\n" +
+					"COMMIT\n"},
+			},
+			wantErr: "failed to add the tproxy rule: exit status 2: RTNETLINK answers: Operation not permitted, the rollback also failed: failed to delete the tproxy rules",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeExecutor(t, tt.records...)
+			err := TProxy{Name: "web", OnPort: 8080}.Install()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want an error starting with %q", err, tt.wantErr)
+			}
+			if got := changes(fake); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got commands\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTProxyRemove(t *testing.T) {
+	missingRule := "iptables: Bad rule (does a matching rule exist in that chain?)."
+	missingRoute := "RTNETLINK answers: No such file or directory"
+	tests := []struct {
+		name    string
+		records []ExecRecord
+		want    []ExecRecord
+	}{
+		{
+			name: "not installed",
+			records: []ExecRecord{
+				{Command: "ip -4 rule del fwmark 0x1/0x1 lookup 100", Output: missingRoute, Error: "exit status 2"},
+				{Command: "ip -4 route del local 0.0.0.0/0 dev lo table 100", Output: missingRoute, Error: "exit status 2"},
+				{Command: tproxyTestDivert, Output: "iptables v1.8.7 (legacy): Couldn't load target `DIVERT-web':No such file or directory", Error: "exit status 2"},
+				{Command: tproxyTestProxy, Output: missingRule, Error: "exit status 1"},
+			},
+			// The divert rule isn't checked as the chain it jumps to doesn't exist
+			want: []ExecRecord{
+				{Command: "ip -4 rule del fwmark 0x1/0x1 lookup 100"},
+				{Command: "ip -4 route del local 0.0.0.0/0 dev lo table 100"},
+				{Command: "iptables -t mangle -vnL --line-numbers"},
+				{Command: tproxyTestProxy},
+			},
+		},
+		{
+			name: "chain left behind",
+			records: []ExecRecord{
+				{Command: "ip -4 rule del fwmark 0x1/0x1 lookup 100", Output: missingRoute, Error: "exit status 2"},
+				{Command: "ip -4 route del local 0.0.0.0/0 dev lo table 100", Output: missingRoute, Error: "exit status 2"},
+				{Command: "iptables -t mangle -vnL --line-numbers", Output: "Chain DIVERT-web (0 references)\n"},
+				{Command: tproxyTestDivert, Output: missingRule, Error: "exit status 1"},
+				{Command: tproxyTestProxy, Output: "iptables: No chain/target/match by that name. Target TPROXY does not exist", Error: "exit status 1"},
+			},
+			want: []ExecRecord{
+				{Command: "ip -4 rule del fwmark 0x1/0x1 lookup 100"},
+				{Command: "ip -4 route del local 0.0.0.0/0 dev lo table 100"},
+				{Command: "iptables -t mangle -vnL --line-numbers"},
+				{Command: tproxyTestDivert},
+				{Command: tproxyTestProxy},
+				{Command: "iptables-restore --noflush", Input: "*mangle\n--flush DIVERT-web\n--delete-chain DIVERT-web\nCOMMIT\n"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeExecutor(t, tt.records...)
+			if err := (TProxy{Name: "web", OnPort: 8080}).Remove(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := changes(fake); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got commands\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
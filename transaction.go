@@ -0,0 +1,417 @@
+package iptables
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	restoreLineRegex = regexp.MustCompile(`line:? (\d+)`)
+)
+
+// NewTransaction returns an empty transaction
+func NewTransaction() *Transaction {
+	return &Transaction{
+		operations: make([]*transactionOp, 0),
+	}
+}
+
+// Transaction collects rule, chain and policy operations and commits them with a single ip(6)tables-restore
+// --noflush call per ip version. Each ip version is applied atomically. When both ip versions are used the ipv4
+// tables touched by the transaction are saved first and restored if the ipv6 payload fails, so the transaction is
+// applied to both ip versions or to neither.
+type Transaction struct {
+	Debug      bool
+	operations []*transactionOp
+}
+
+type transactionOp struct {
+	ipVer   IPVer
+	table   Table
+	line    string
+	rule    *Rule
+	command Cmd
+	number  int
+}
+
+// render returns the restore line for the operation
+func (op *transactionOp) render() string {
+	if op.rule == nil {
+		return op.line
+	}
+	op.rule.command = op.command
+	if op.command == CmdInsert || op.command == CmdReplace {
+		op.rule.Number = op.number
+	}
+	return strings.Join(op.rule.commandSpecification(), " ")
+}
+
+// TransactionError is returned when ip(6)tables-restore rejects a transaction. Line is the line of the payload
+// that failed or 0 if the failing line could not be determined. Applied lists the ip versions that were already
+// committed and are still in place, which only happens when rolling them back failed with RollbackErr
+type TransactionError struct {
+	IpVersion   IPVer
+	Line        int
+	Content     string
+	Output      string
+	Err         error
+	RolledBack  []IPVer
+	Applied     []IPVer
+	RollbackErr error
+}
+
+func (e *TransactionError) Error() string {
+	output := strings.TrimSpace(e.Output)
+	message := fmt.Sprintf("%s transaction failed: %v: %s", e.IpVersion, e.Err, output)
+	if e.Line > 0 {
+		message = fmt.Sprintf("%s transaction failed at line %d '%s': %s", e.IpVersion, e.Line, e.Content, output)
+	}
+	for _, ver := range e.RolledBack {
+		message += fmt.Sprintf(", the %s changes were rolled back", ver)
+	}
+	for _, ver := range e.Applied {
+		message += fmt.Sprintf(", the %s changes were already applied and could not be rolled back: %v", ver, e.RollbackErr)
+	}
+	return message
+}
+
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+func (t *Transaction) addRule(rule *Rule, command Cmd, number int) {
+	rule.setDefaults()
+	table := rule.Table
+	if table == "" {
+		table = TableFilter
+	}
+	t.operations = append(t.operations, &transactionOp{
+		ipVer:   rule.IpVersion,
+		table:   table,
+		rule:    rule,
+		command: command,
+		number:  number,
+	})
+}
+
+func (t *Transaction) addLine(ver IPVer, table Table, command Cmd, args ...string) {
+	if ver == "" {
+		ver = IPv4
+	}
+	parts := append([]string{fmt.Sprintf("--%s", command)}, args...)
+	t.operations = append(t.operations, &transactionOp{
+		ipVer:   ver,
+		table:   table,
+		line:    strings.Join(parts, " "),
+		command: command,
+	})
+}
+
+// Append adds the rule to the end of its chain when the transaction is committed
+func (t *Transaction) Append(rule *Rule) {
+	t.addRule(rule, CmdAppend, 0)
+}
+
+// Insert inserts the rule at the index of its chain when the transaction is committed
+func (t *Transaction) Insert(rule *Rule, index int) {
+	t.addRule(rule, CmdInsert, index)
+}
+
+// Replace replaces the rule at the rule's Number in its chain when the transaction is committed
+func (t *Transaction) Replace(rule *Rule) {
+	t.addRule(rule, CmdReplace, rule.Number)
+}
+
+// Delete deletes the rule by its specification when the transaction is committed
+func (t *Transaction) Delete(rule *Rule) {
+	t.addRule(rule, CmdDelete, 0)
+}
+
+// NewChain creates a user defined chain when the transaction is committed
+func (t *Transaction) NewChain(ver IPVer, table Table, chain Chain) {
+	t.addLine(ver, table, CmdNewChain, string(chain))
+}
+
+// DeleteChain deletes an empty user defined chain when the transaction is committed
+func (t *Transaction) DeleteChain(ver IPVer, table Table, chain Chain) {
+	t.addLine(ver, table, CmdDeleteChain, string(chain))
+}
+
+// FlushChain deletes all of the rules in the chain when the transaction is committed
+func (t *Transaction) FlushChain(ver IPVer, table Table, chain Chain) {
+	t.addLine(ver, table, CmdFlush, string(chain))
+}
+
+// RenameChain renames a user defined chain when the transaction is committed
+func (t *Transaction) RenameChain(ver IPVer, table Table, chain Chain, newName Chain) {
+	t.addLine(ver, table, CmdRenameChain, string(chain), string(newName))
+}
+
+// SetPolicy sets the policy of a built-in chain when the transaction is committed
+func (t *Transaction) SetPolicy(ver IPVer, table Table, chain Chain, policy string) {
+	t.addLine(ver, table, CmdPolicy, string(chain), policy)
+}
+
+// deleteSpec deletes a rule using the specification printed by iptables -S when the transaction is committed
+func (t *Transaction) deleteSpec(ver IPVer, table Table, spec string) {
+	spec = strings.TrimSpace(spec)
+	spec = strings.TrimPrefix(strings.TrimPrefix(spec, "-A "), "-I ")
+	t.addLine(ver, table, CmdDelete, spec)
+}
+
+// Len returns the number of operations in the transaction
+func (t *Transaction) Len() int {
+	return len(t.operations)
+}
+
+// Payload returns the ip(6)tables-restore input for the operations of the ip version. The payload is empty when
+// the transaction has no operations for the ip version
+func (t *Transaction) Payload(ver IPVer) string {
+	payload, _ := t.render(ver)
+	return payload
+}
+
+// render builds the restore payload for the ip version along with a map of payload line numbers to the operations
+// that produced them. Operations are grouped by table while keeping their order within each table
+func (t *Transaction) render(ver IPVer) (payload string, lines map[int]*transactionOp) {
+	lines = make(map[int]*transactionOp)
+	order := make([]Table, 0)
+	grouped := make(map[Table][]*transactionOp)
+	for _, op := range t.operations {
+		if op.ipVer != ver {
+			continue
+		}
+		if _, ok := grouped[op.table]; !ok {
+			order = append(order, op.table)
+		}
+		grouped[op.table] = append(grouped[op.table], op)
+	}
+
+	output := make([]string, 0)
+	for _, table := range order {
+		output = append(output, fmt.Sprintf("*%s", table))
+		for _, op := range grouped[table] {
+			output = append(output, op.render())
+			lines[len(output)] = op
+		}
+		output = append(output, "COMMIT")
+	}
+
+	if len(output) == 0 {
+		return "", lines
+	}
+
+	return strings.Join(output, "\n") + "\n", lines
+}
+
+// Commit validates every rule in the transaction and then applies the operations. When the transaction uses both
+// ip versions the ipv4 tables it touches are saved with their counters before it is applied and restored if the
+// ipv6 payload is rejected. Changes made to those tables by other programs while the transaction is committed are
+// lost by the restore, and the packet and byte counters of their rules are set back to the values they had when
+// they were saved
+func (t *Transaction) Commit() (err error) {
+	if err = t.validate(); err != nil {
+		return err
+	}
+
+	snapshot := ""
+	if t.Payload(IPv4) != "" && t.Payload(IPv6) != "" {
+		snapshot, err = t.snapshot(IPv4)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = t.commit(IPv4); err != nil {
+		return err
+	}
+
+	if err = t.commit(IPv6); err != nil {
+		if snapshot == "" {
+			return err
+		}
+		terr, ok := err.(*TransactionError)
+		if !ok {
+			terr = &TransactionError{IpVersion: IPv6, Err: err}
+		}
+		if rerr := t.rollback(IPv4, snapshot); rerr != nil {
+			terr.Applied = append(terr.Applied, IPv4)
+			terr.RollbackErr = rerr
+		} else {
+			terr.RolledBack = append(terr.RolledBack, IPv4)
+		}
+		return terr
+	}
+
+	return nil
+}
+
+// validate checks the target and matches of every rule in the transaction. The rules that are already in place are
+// listed once per ip version to make sure the appended and inserted rules don't reuse the id or name of an existing
+// rule or of another rule in the transaction
+func (t *Transaction) validate() error {
+	staged := make(map[IPVer][]string)
+	seen := make(map[IPVer]map[string]bool)
+	hasRules := false
+	for _, op := range t.operations {
+		if op.rule == nil {
+			continue
+		}
+		hasRules = true
+		op.rule.command = op.command
+		if err := op.rule.validateSpec(); err != nil {
+			return err
+		}
+		if op.command != CmdInsert && op.command != CmdAppend {
+			continue
+		}
+		if seen[op.ipVer] == nil {
+			seen[op.ipVer] = make(map[string]bool)
+		}
+		for _, field := range [][2]string{{"id", op.rule.Id}, {"name", op.rule.Name}} {
+			if field[1] == "" {
+				continue
+			}
+			comment := field[0] + ":" + field[1]
+			if seen[op.ipVer][comment] {
+				return fmt.Errorf("the transaction adds more than one rule with the %s %s", field[0], field[1])
+			}
+			seen[op.ipVer][comment] = true
+			staged[op.ipVer] = append(staged[op.ipVer], comment)
+		}
+	}
+
+	for _, ver := range ipVersions {
+		if len(staged[ver]) == 0 {
+			continue
+		}
+		existing, err := versionComments(ver)
+		if err != nil {
+			return fmt.Errorf("failed to check if the %s rules of the transaction already exist: %w", ver, err)
+		}
+		for _, comment := range staged[ver] {
+			if existing[comment] {
+				field := strings.SplitN(comment, ":", 2)
+				return fmt.Errorf("a rule with the %s %s already exists", field[0], field[1])
+			}
+		}
+	}
+
+	// Make sure we are running as root so we can manipulate iptables
+	if hasRules && requiresRoot() && !RunningAsRoot() {
+		return fmt.Errorf("this application must be run as root to manipulate ip(6)tables")
+	}
+
+	return nil
+}
+
+// tables returns the tables the transaction touches for the ip version
+func (t *Transaction) tables(ver IPVer) []Table {
+	tables := make([]Table, 0)
+	seen := make(map[Table]bool)
+	for _, op := range t.operations {
+		if op.ipVer == ver && !seen[op.table] {
+			seen[op.table] = true
+			tables = append(tables, op.table)
+		}
+	}
+	return tables
+}
+
+// snapshot returns the ip(6)tables-save output of the tables the transaction touches for the ip version including
+// the rule and chain counters
+func (t *Transaction) snapshot(ver IPVer) (snapshot string, err error) {
+	save, err := GetIptablesSaveBinaryPath(string(ver))
+	if err != nil {
+		return "", err
+	}
+	for _, table := range t.tables(ver) {
+		result, err := run(fmt.Sprintf("%s -c -t %s", save, table))
+		if err != nil {
+			return "", fmt.Errorf("failed to save the %s %s table before the transaction: %w: %s", ver, table, err, strings.TrimSpace(result))
+		}
+		snapshot += result
+		if !strings.HasSuffix(snapshot, "\n") {
+			snapshot += "\n"
+		}
+	}
+	return snapshot, nil
+}
+
+// rollback restores the tables saved by snapshot along with their counters. Restoring without --noflush replaces
+// only the tables that are in the snapshot
+func (t *Transaction) rollback(ver IPVer, snapshot string) error {
+	restore, err := iptablesRestoreCommand(ver)
+	if err != nil {
+		return err
+	}
+	if t.Debug {
+		log.Println(snapshot)
+	}
+	result, err := runWithInput(fmt.Sprintf("%s --counters", restore), snapshot)
+	if t.Debug {
+		log.Println(result)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(result))
+	}
+	for _, op := range t.operations {
+		if op.ipVer == ver && op.rule != nil {
+			op.rule.setState(false, false)
+		}
+	}
+	return nil
+}
+
+func (t *Transaction) commit(ver IPVer) (err error) {
+	payload, lines := t.render(ver)
+	if payload == "" {
+		return nil
+	}
+
+	restore, err := iptablesRestoreCommand(ver)
+	if err != nil {
+		return err
+	}
+
+	if t.Debug {
+		log.Println(payload)
+	}
+
+	result, err := runWithInput(fmt.Sprintf("%s --noflush", restore), payload)
+	if t.Debug {
+		log.Println(result)
+	}
+
+	if err != nil {
+		terr := &TransactionError{
+			IpVersion: ver,
+			Output:    result,
+			Err:       err,
+		}
+		if m := restoreLineRegex.FindStringSubmatch(result); m != nil {
+			terr.Line, _ = strconv.Atoi(m[1])
+			payloadLines := strings.Split(payload, "\n")
+			if terr.Line > 0 && terr.Line <= len(payloadLines) {
+				terr.Content = payloadLines[terr.Line-1]
+			}
+		}
+		for _, op := range lines {
+			if op.rule != nil {
+				op.rule.setState(false, false)
+			}
+		}
+		return terr
+	}
+
+	for _, op := range lines {
+		if op.rule != nil {
+			op.rule.setState(true, true)
+		}
+	}
+
+	return nil
+}
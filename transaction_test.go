@@ -0,0 +1,261 @@
+package iptables
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// transactionRule returns an accept rule for the chain of the filter table
+func transactionRule(id string, ver IPVer, chain Chain) *Rule {
+	r := NewRule(id, nil)
+	r.IpVersion = ver
+	r.Table = TableFilter
+	r.Chain = chain
+	r.Protocol = ProtocolTCP
+	r.Target = &TargetJump{Value: "ACCEPT"}
+	return r
+}
+
+func TestTransactionPayload(t *testing.T) {
+	useFakeExecutor(t)
+	nat := NewRule("web", nil)
+	nat.Table = TableNat
+	nat.Chain = ChainPreRouting
+	nat.Protocol = ProtocolTCP
+	nat.Target = &TargetRedirect{DestinationPort: "8080"}
+
+	tx := NewTransaction()
+	tx.NewChain(IPv4, TableFilter, "SSH")
+	tx.Append(nat)
+	tx.Append(transactionRule("ssh", IPv4, "SSH"))
+	tx.Insert(transactionRule("first", IPv4, ChainInput), 1)
+	tx.SetPolicy(IPv4, TableFilter, ChainInput, "DROP")
+	tx.RenameChain(IPv4, TableFilter, "SSH", "SSH-IN")
+	tx.Append(transactionRule("ssh6", IPv6, ChainInput))
+
+	want := "*filter\n" +
+		"--new-chain SSH\n" +
+		"--append SSH --protocol tcp -m comment --comment \"id:ssh\" --jump ACCEPT\n" +
+		"--insert INPUT 1 --protocol tcp -m comment --comment \"id:first\" --jump ACCEPT\n" +
+		"--policy INPUT DROP\n" +
+		"--rename-chain SSH SSH-IN\n" +
+		"COMMIT\n" +
+		"*nat\n" +
+		"--append PREROUTING --protocol tcp -m comment --comment \"id:web\" --jump REDIRECT --to-ports 8080\n" +
+		"COMMIT\n"
+	if got := tx.Payload(IPv4); got != want {
+		t.Errorf("got ipv4 payload\n%s\nwant\n%s", got, want)
+	}
+	want6 := "*filter\n--append INPUT --protocol tcp -m comment --comment \"id:ssh6\" --jump ACCEPT\nCOMMIT\n"
+	if got := tx.Payload(IPv6); got != want6 {
+		t.Errorf("got ipv6 payload\n%s\nwant\n%s", got, want6)
+	}
+	if tx.Len() != 7 {
+		t.Errorf("got %d operations, want 7", tx.Len())
+	}
+}
+
+func TestTransactionError(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		line    int
+		content string
+		message string
+	}{
+		{
+			name:    "legacy",
+			output:  "iptables-restore: line 3 failed\n",
+			line:    3,
+			content: "--append INPUT --protocol tcp -m comment --comment \"id:second\" --jump ACCEPT",
+			message: "ipv4 transaction failed at line 3 '--append INPUT --protocol tcp -m comment --comment \"id:second\" --jump ACCEPT': iptables-restore: line 3 failed",
+		},
+		{
+			name:    "nft",
+			output:  "iptables-restore v1.8.7 (nf_tables): line 2: RULE_APPEND failed (No such file or directory): rule in chain INPUT\n",
+			line:    2,
+			content: "--append INPUT --protocol tcp -m comment --comment \"id:first\" --jump ACCEPT",
+			message: "ipv4 transaction failed at line 2 '--append INPUT --protocol tcp -m comment --comment \"id:first\" --jump ACCEPT': iptables-restore v1.8.7 (nf_tables): line 2: RULE_APPEND failed (No such file or directory): rule in chain INPUT",
+		},
+		{
+			name:    "error occurred at line",
+			output:  "Error occurred at line: 4\nTry `iptables-restore -h' or 'iptables-restore --help' for more information.\n",
+			line:    4,
+			content: "COMMIT",
+			message: "ipv4 transaction failed at line 4 'COMMIT': Error occurred at line: 4\nTry `iptables-restore -h' or 'iptables-restore --help' for more information.",
+		},
+		{
+			name:    "no line",
+			output:  "iptables-restore: unable to initialize table 'filter'\n",
+			message: "ipv4 transaction failed: exit status 1: iptables-restore: unable to initialize table 'filter'",
+		},
+		{
+			name:    "line out of range",
+			output:  "iptables-restore: line 9 failed\n",
+			line:    9,
+			message: "ipv4 transaction failed at line 9 '': iptables-restore: line 9 failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeExecutor(t, ExecRecord{Command: "iptables-restore --noflush", Output: tt.output, Error: "exit status 1"})
+			first := transactionRule("first", IPv4, ChainInput)
+			second := transactionRule("second", IPv4, ChainInput)
+			tx := NewTransaction()
+			tx.Append(first)
+			tx.Append(second)
+
+			err := tx.Commit()
+			var terr *TransactionError
+			if !errors.As(err, &terr) {
+				t.Fatalf("got error %v, want a TransactionError", err)
+			}
+			if terr.IpVersion != IPv4 || terr.Line != tt.line || terr.Content != tt.content {
+				t.Errorf("got version %s line %d content %q, want ipv4 line %d content %q", terr.IpVersion, terr.Line, terr.Content, tt.line, tt.content)
+			}
+			if terr.Error() != tt.message {
+				t.Errorf("got message %q, want %q", terr.Error(), tt.message)
+			}
+			if first.Applied || second.Applied {
+				t.Errorf("rules of a failed transaction are marked as applied")
+			}
+		})
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	fake := useFakeExecutor(t)
+	r := transactionRule("ssh", IPv4, ChainInput)
+	tx := NewTransaction()
+	tx.Append(r)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []ExecRecord{{Command: "iptables-restore --noflush", Input: tx.Payload(IPv4)}}
+	if got := changes(fake); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %+v, want %+v", got, want)
+	}
+	if !r.Applied || !r.Valid {
+		t.Errorf("rule of a committed transaction isn't marked as applied")
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	snapshot := "*filter\n:INPUT ACCEPT [12:3480]\n[4:240] -A INPUT -p tcp -j ACCEPT\nCOMMIT\n"
+	tests := []struct {
+		name       string
+		rollback   ExecRecord
+		rolledBack []IPVer
+		applied    []IPVer
+		message    string
+	}{
+		{
+			name:       "rolled back",
+			rollback:   ExecRecord{Command: "iptables-restore --counters"},
+			rolledBack: []IPVer{IPv4},
+			message:    ", the ipv4 changes were rolled back",
+		},
+		{
+			name:     "rollback failed",
+			rollback: ExecRecord{Command: "iptables-restore --counters", Output: "iptables-restore: line 2 failed", Error: "exit status 1"},
+			applied:  []IPVer{IPv4},
+			message:  ", the ipv4 changes were already applied and could not be rolled back: exit status 1: iptables-restore: line 2 failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeExecutor(t,
+				ExecRecord{Command: "iptables-save -c -t filter", Output: snapshot},
+				ExecRecord{Command: "ip6tables-restore --noflush", Output: "ip6tables-restore: line 2 failed", Error: "exit status 1"},
+				tt.rollback,
+			)
+			r4 := transactionRule("ssh", IPv4, ChainInput)
+			r6 := transactionRule("ssh6", IPv6, ChainInput)
+			tx := NewTransaction()
+			tx.Append(r4)
+			tx.Append(r6)
+
+			err := tx.Commit()
+			var terr *TransactionError
+			if !errors.As(err, &terr) {
+				t.Fatalf("got error %v, want a TransactionError", err)
+			}
+			if terr.IpVersion != IPv6 || !reflect.DeepEqual(terr.RolledBack, tt.rolledBack) || !reflect.DeepEqual(terr.Applied, tt.applied) {
+				t.Errorf("got version %s rolled back %v applied %v, want ipv6 rolled back %v applied %v", terr.IpVersion, terr.RolledBack, terr.Applied, tt.rolledBack, tt.applied)
+			}
+			if !strings.HasSuffix(terr.Error(), tt.message) {
+				t.Errorf("got message %q, want it to end with %q", terr.Error(), tt.message)
+			}
+
+			want := []ExecRecord{
+				{Command: "iptables-save -c -t filter"},
+				{Command: "iptables-restore --noflush", Input: tx.Payload(IPv4)},
+				{Command: "ip6tables-restore --noflush", Input: tx.Payload(IPv6)},
+				{Command: "iptables-restore --counters", Input: snapshot},
+			}
+			if got := changes(fake); !reflect.DeepEqual(got, want) {
+				t.Errorf("got commands %+v, want %+v", got, want)
+			}
+			if r6.Applied || (r4.Applied && tt.rolledBack != nil) {
+				t.Errorf("rolled back rules are marked as applied")
+			}
+		})
+	}
+}
+
+func TestTransactionValidation(t *testing.T) {
+	listing := "-P INPUT ACCEPT\n-A INPUT -p tcp -m comment --comment \"id:ssh\" -j ACCEPT\n"
+	tests := []struct {
+		name    string
+		rules   []*Rule
+		wantErr string
+	}{
+		{
+			name:  "new ids",
+			rules: []*Rule{transactionRule("web", IPv4, ChainInput), transactionRule("dns", IPv4, ChainInput), transactionRule("web", IPv6, ChainInput)},
+		},
+		{
+			name:    "existing id",
+			rules:   []*Rule{transactionRule("web", IPv4, ChainInput), transactionRule("ssh", IPv4, ChainInput)},
+			wantErr: "a rule with the id ssh already exists",
+		},
+		{
+			name:    "duplicate id",
+			rules:   []*Rule{transactionRule("web", IPv4, ChainInput), transactionRule("web", IPv4, ChainOutput)},
+			wantErr: "the transaction adds more than one rule with the id web",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeExecutor(t, ExecRecord{Command: "iptables -t filter -S", Output: listing})
+			tx := NewTransaction()
+			for _, r := range tt.rules {
+				tx.Append(r)
+			}
+
+			err := tx.Commit()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+
+			listings := make(map[string]int)
+			for _, command := range fake.Commands() {
+				if strings.HasSuffix(command, " -S") {
+					listings[command] += 1
+				}
+			}
+			for command, count := range listings {
+				if count > 1 {
+					t.Errorf("%s was run %d times, want once", command, count)
+				}
+			}
+			if tt.wantErr != "" && len(changes(fake)) != 0 {
+				t.Errorf("an invalid transaction ran %+v", changes(fake))
+			}
+		})
+	}
+}
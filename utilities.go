@@ -2,68 +2,96 @@ package iptables
 
 import (
 	"fmt"
-	"github.com/BGrewell/go-execute"
 	"os"
-	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 )
 
-// TODO: There is a problem with how all of these methods implement their functionality. They use line numbers
-//		 which can change if a rule is added/removed between the enumeration and the execution. This should be
-//		 addressed at some point although for this use case it is a low probability event as we use locks which
-//		 prevent race conditions in our code so it would have to happen by external modification which is less
-//		 likely in our use cases.
+// NOTE: The delete functions below remove rules by their full specification as reported by iptables -S rather
+//		 than by line number. Line numbers can change if a rule is added/removed between the enumeration and the
+//		 execution by something outside of this package, a specification always refers to the rule that was matched.
 
 var (
 	errNoMatch = fmt.Errorf("no matching rule was found")
 	tableLock  = sync.Mutex{}
 )
 
+var (
+	ipVersions = []IPVer{IPv4, IPv6}
+)
+
 type RuleLocation struct {
-	Table string
-	Chain string
-	Line  string
+	IpVersion IPVer
+	Table     string
+	Chain     string
+	Line      string
+	Spec      string
 }
 
+// CommentExists returns true if a rule with the comment exists. False is also returned when the rules can't be
+// listed
 func CommentExists(comment string) bool {
 	tableLock.Lock()
 	defer tableLock.Unlock()
 	_, err := FindRuleByComment(comment)
-	if err != nil && err == errNoMatch {
-		return false
-	}
-	return true
+	return err == nil
 }
 
+// IdExists returns true if a rule with the id exists. False is also returned when the rules can't be listed, use
+// IdExistsWithError to tell the two apart
 func IdExists(id string) bool {
+	exists, _ := idExists(ipVersions, id)
+	return exists
+}
+
+// IdExistsWithError returns if a rule with the id exists and the error when the rules can't be listed
+func IdExistsWithError(id string) (bool, error) {
+	return idExists(ipVersions, id)
+}
+
+func idExists(vers []IPVer, id string) (bool, error) {
 	tableLock.Lock()
 	defer tableLock.Unlock()
-	_, err := FindRuleById(id)
-	if err != nil && err == errNoMatch {
-		return false
-	}
-	return true
+	prefix := "id"
+	return ruleExists(findRuleByCommentWithPrefix(vers, id, &prefix))
 }
 
+// NameExists returns true if a rule with the name exists. False is also returned when the rules can't be listed,
+// use NameExistsWithError to tell the two apart
 func NameExists(name string) bool {
+	exists, _ := nameExists(ipVersions, name)
+	return exists
+}
+
+// NameExistsWithError returns if a rule with the name exists and the error when the rules can't be listed
+func NameExistsWithError(name string) (bool, error) {
+	return nameExists(ipVersions, name)
+}
+
+func nameExists(vers []IPVer, name string) (bool, error) {
 	tableLock.Lock()
 	defer tableLock.Unlock()
-	_, err := FindRuleByName(name)
-	if err != nil && err == errNoMatch {
-		return false
-	}
-	return true
+	prefix := "name"
+	return ruleExists(findRuleByCommentWithPrefix(vers, name, &prefix))
 }
 
+// AppExists returns true if a rule with the app exists. False is also returned when the rules can't be listed
 func AppExists(app string) bool {
 	tableLock.Lock()
 	defer tableLock.Unlock()
 	_, err := FindRuleByApp(app)
-	if err != nil && err == errNoMatch {
-		return false
+	return err == nil
+}
+
+// ruleExists converts the result of a lookup into whether the rule exists, errNoMatch isn't an error
+func ruleExists(location *RuleLocation, err error) (bool, error) {
+	if err == errNoMatch {
+		return false, nil
+	} else if err != nil {
+		return false, err
 	}
-	return true
+	return true, nil
 }
 
 func DeleteByComment(comment string) error {
@@ -73,12 +101,7 @@ func DeleteByComment(comment string) error {
 	if err != nil {
 		return err
 	}
-	deleteCmd := fmt.Sprintf("iptables -t %s -D %s %s", location.Table, location.Chain, location.Line)
-	_, err = execute.ExecuteCmd(deleteCmd)
-	if err != nil {
-		return err
-	}
-	return nil
+	return deleteLocation(location)
 }
 
 func DeleteById(id string) error {
@@ -88,12 +111,7 @@ func DeleteById(id string) error {
 	if err != nil {
 		return err
 	}
-	deleteCmd := fmt.Sprintf("iptables -t %s -D %s %s", location.Table, location.Chain, location.Line)
-	_, err = execute.ExecuteCmd(deleteCmd)
-	if err != nil {
-		return err
-	}
-	return nil
+	return deleteLocation(location)
 }
 
 func DeleteByName(name string) error {
@@ -103,12 +121,7 @@ func DeleteByName(name string) error {
 	if err != nil {
 		return err
 	}
-	deleteCmd := fmt.Sprintf("iptables -t %s -D %s %s", location.Table, location.Chain, location.Line)
-	_, err = execute.ExecuteCmd(deleteCmd)
-	if err != nil {
-		return err
-	}
-	return nil
+	return deleteLocation(location)
 }
 
 func DeleteByApp(app string) error {
@@ -118,12 +131,7 @@ func DeleteByApp(app string) error {
 	if err != nil {
 		return err
 	}
-	deleteCmd := fmt.Sprintf("iptables -t %s -D %s %s", location.Table, location.Chain, location.Line)
-	_, err = execute.ExecuteCmd(deleteCmd)
-	if err != nil {
-		return err
-	}
-	return nil
+	return deleteLocation(location)
 }
 
 func DeleteAllMatchingComments(comment string) error {
@@ -197,59 +205,130 @@ func FindRuleByApp(app string) (location *RuleLocation, err error) {
 	return FindRuleByCommentWithPrefix(app, &prefix)
 }
 
+// FindRuleByCommentWithPrefix searches the IPv4 rules and then the IPv6 rules for the first rule with a matching
+// comment
 func FindRuleByCommentWithPrefix(comment string, prefix *string) (location *RuleLocation, err error) {
+	return findRuleByCommentWithPrefix(ipVersions, comment, prefix)
+}
+
+// findRuleByCommentWithPrefix searches each ip version in turn. An ip version whose ip(6)tables binary isn't
+// installed has no rules and is skipped. An ip version that can't be listed doesn't stop the search, its error is
+// only returned when no other ip version has a matching rule
+func findRuleByCommentWithPrefix(vers []IPVer, comment string, prefix *string) (location *RuleLocation, err error) {
+	var lastErr error
+	for _, ver := range vers {
+		if _, err = GetIptablesBinaryPath(string(ver)); err != nil {
+			continue
+		}
+		location, err = findVersionRuleByCommentWithPrefix(ver, comment, prefix)
+		if err == nil {
+			return location, nil
+		} else if err != errNoMatch {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, errNoMatch
+}
+
+func findVersionRuleByCommentWithPrefix(ver IPVer, comment string, prefix *string) (location *RuleLocation, err error) {
+	ipt, err := iptablesCommand(ver)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, table := range tables {
-		chains, err := EnumerateChains(table)
+		listCmd := fmt.Sprintf("%s -t %s -S", ipt, table)
+		result, err := run(listCmd)
 		if err != nil {
 			return nil, err
 		}
-		for _, chain := range chains {
-			rules, err := EnumerateRules(table, chain)
-			if err != nil {
-				return nil, err
+
+		lineNumbers := make(map[string]int)
+		for _, rule := range strings.Split(result, "\n") {
+			if !strings.HasPrefix(rule, "-A ") {
+				continue
 			}
+			fields := strings.Fields(rule)
+			chain := fields[1]
+			lineNumbers[chain] += 1
+
+			for _, c := range ruleComments(rule) {
+				match := comment
+				if prefix == nil {
+					// strip off app: | id: | name: prefix's
+					c = strings.ReplaceAll(c, "app:", "")
+					c = strings.ReplaceAll(c, "id:", "")
+					c = strings.ReplaceAll(c, "name:", "")
+				} else {
+					match = fmt.Sprintf("%s:%s", *prefix, comment)
+				}
 
-			for _, rule := range rules {
-				mark := 0
-				for mark < len(rule) {
-					start := strings.Index(rule[mark:], "/* ")
-					end := strings.Index(rule[mark:], " */")
-					if start == -1 || end == -1 {
-						break
+				if match == c {
+					l := &RuleLocation{
+						IpVersion: ver,
+						Table:     table,
+						Chain:     chain,
+						Line:      strconv.Itoa(lineNumbers[chain]),
+						Spec:      rule,
 					}
+					return l, nil
+				}
+			}
+		}
+	}
+	return nil, errNoMatch
+}
 
-					// NOTE: there is a strange issue where comments quoted on the cmd line don't have comments in the
-					// output but for some reason when it is done through this module they do have the double quotes on
-					// the comment so we need to deal with them being there or not being there
-					// trim off the markers and spaces
-					c := rule[mark+start+3 : mark+end]
-					c = strings.ReplaceAll(c, "\"", "")
-
-					match := comment
-					if prefix == nil {
-						// strip off app: | id: | name: prefix's
-						c = strings.ReplaceAll(c, "app:", "")
-						c = strings.ReplaceAll(c, "id:", "")
-						c = strings.ReplaceAll(c, "name:", "")
-					} else {
-						match = fmt.Sprintf("%s:%s", *prefix, comment)
-					}
+// ruleComments returns the values of all of the comment matches in a rule as printed by iptables -S
+func ruleComments(rule string) (comments []string) {
+	comments = make([]string, 0)
+	args, err := splitArgs(rule)
+	if err != nil {
+		return comments
+	}
+	for idx := 0; idx+1 < len(args); idx++ {
+		if args[idx] == "--comment" {
+			comments = append(comments, unquoteArg(args[idx+1]))
+		}
+	}
+	return comments
+}
 
-					mark = mark + end + 2
-					if match == c {
-						l := &RuleLocation{
-							Table: table,
-							Chain: chain,
-							Line:  strings.Fields(rule)[0],
-						}
-						return l, nil
-					}
-				}
+// versionComments returns the values of the comment matches of every rule of the ip version. Each table is listed
+// once no matter how many comments are looked up afterwards
+func versionComments(ver IPVer) (comments map[string]bool, err error) {
+	ipt, err := iptablesCommand(ver)
+	if err != nil {
+		return nil, err
+	}
 
+	comments = make(map[string]bool)
+	for _, table := range tables {
+		listCmd := fmt.Sprintf("%s -t %s -S", ipt, table)
+		result, err := run(listCmd)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range strings.Split(result, "\n") {
+			if !strings.HasPrefix(rule, "-A ") {
+				continue
+			}
+			for _, c := range ruleComments(rule) {
+				comments[c] = true
 			}
 		}
 	}
-	return nil, errNoMatch
+	return comments, nil
+}
+
+// deleteLocation deletes the rule at the location by its specification using an iptables-restore transaction
+func deleteLocation(location *RuleLocation) error {
+	t := NewTransaction()
+	t.deleteSpec(location.IpVersion, Table(location.Table), location.Spec)
+	return t.Commit()
 }
 
 func GetPolicy(table string, chain string) (policy string, err error) {
@@ -261,12 +340,12 @@ func Get6Policy(table string, chain string) (policy string, err error) {
 }
 
 func getPolicy(ver IPVer, table string, chain string) (policy string, err error) {
-	ipt, err := GetIptablesBinaryPath(string(ver))
+	ipt, err := iptablesCommand(ver)
 	if err != nil {
 		return "", err
 	}
 	listCmd := fmt.Sprintf("%s -t %s -S %s", ipt, table, chain)
-	result, err := execute.ExecuteCmd(listCmd)
+	result, err := run(listCmd)
 	if err != nil {
 		return "", err
 	}
@@ -292,12 +371,12 @@ func Enumerate6Rules(table string, chain string) (rules []string, err error) {
 }
 
 func enumerateRules(ver IPVer, table string, chain string) (rules []string, err error) {
-	v := ""
-	if ver == IPv6 {
-		v = "6"
+	ipt, err := iptablesCommand(ver)
+	if err != nil {
+		return nil, err
 	}
-	listCmd := fmt.Sprintf("ip%stables -t %s -vnL %s --line-numbers", v, table, chain)
-	result, err := execute.ExecuteCmd(listCmd)
+	listCmd := fmt.Sprintf("%s -t %s -vnL %s --line-numbers", ipt, table, chain)
+	result, err := run(listCmd)
 	if err != nil {
 		return nil, err
 	}
@@ -315,8 +394,12 @@ func Enumerate6Chains(table string) (chains []string, err error) {
 
 func enumerateChains(ver IPVer, table string) (chains []string, err error) {
 	chains = make([]string, 0)
-	listCmd := fmt.Sprintf("iptables -t %s -vnL --line-numbers", table)
-	result, err := execute.ExecuteCmd(listCmd)
+	ipt, err := iptablesCommand(ver)
+	if err != nil {
+		return nil, err
+	}
+	listCmd := fmt.Sprintf("%s -t %s -vnL --line-numbers", ipt, table)
+	result, err := run(listCmd)
 	if err != nil {
 		return nil, err
 	}
@@ -344,7 +427,7 @@ func enumerateUsedTables(ver IPVer) (tables []string, err error) {
 	if ver == IPv6 {
 		cmd = "cat /proc/net/ip6_tables_names"
 	}
-	result, err := execute.ExecuteCmd(cmd)
+	result, err := run(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -358,7 +441,15 @@ func enumerateUsedTables(ver IPVer) (tables []string, err error) {
 }
 
 func ValidChain(table string, chain string) bool {
-	chains, _ := EnumerateChains(table)
+	return validChain(IPv4, table, chain)
+}
+
+func Valid6Chain(table string, chain string) bool {
+	return validChain(IPv6, table, chain)
+}
+
+func validChain(ver IPVer, table string, chain string) bool {
+	chains, _ := enumerateChains(ver, table)
 	for _, c := range chains {
 		if c == chain {
 			return true
@@ -372,6 +463,18 @@ func RunningAsRoot() bool {
 }
 
 func GetIptablesBinaryPath(ipVer string) (cmd string, err error) {
+	return getBinaryPath(ipVer, "")
+}
+
+func GetIptablesRestoreBinaryPath(ipVer string) (cmd string, err error) {
+	return getBinaryPath(ipVer, "-restore")
+}
+
+func GetIptablesSaveBinaryPath(ipVer string) (cmd string, err error) {
+	return getBinaryPath(ipVer, "-save")
+}
+
+func getBinaryPath(ipVer string, suffix string) (cmd string, err error) {
 	var binaryName string
 	if ipVer == "ipv6" {
 		binaryName = "ip6tables"
@@ -379,7 +482,7 @@ func GetIptablesBinaryPath(ipVer string) (cmd string, err error) {
 		binaryName = "iptables"
 	}
 
-	path, err := exec.LookPath(binaryName)
+	path, err := GetExecutor().LookPath(binaryName + suffix)
 	if err != nil {
 		return "", err
 	}
@@ -393,5 +496,3 @@ func GetNegatedPattern(negated bool) string {
 	}
 	return ""
 }
-
-// TODO: Need to make sure all of these functions work with ipv4 and ipv6 as those are different iptables
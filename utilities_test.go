@@ -0,0 +1,45 @@
+package iptables
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// missingExecutor is a FakeExecutor for a host where some of the binaries aren't installed
+type missingExecutor struct {
+	*FakeExecutor
+	missing map[string]bool
+}
+
+func (e *missingExecutor) LookPath(name string) (path string, err error) {
+	if e.missing[name] {
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	return e.FakeExecutor.LookPath(name)
+}
+
+func TestLookupWithoutIp6tables(t *testing.T) {
+	listing := "-P INPUT ACCEPT\n-A INPUT -p tcp -m comment --comment \"id:ssh\" -j ACCEPT\n"
+	fake := useFakeExecutor(t,
+		ExecRecord{Command: "iptables -t filter -S", Output: listing},
+		ExecRecord{Command: "iptables -t filter -S", Output: "-P INPUT ACCEPT\n"},
+	)
+	SetExecutor(&missingExecutor{FakeExecutor: fake, missing: map[string]bool{"ip6tables": true}})
+
+	if err := DeleteAllMatchingId("ssh"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []ExecRecord{{
+		Command: "iptables-restore --noflush",
+		Input:   "*filter\n--delete INPUT -p tcp -m comment --comment \"id:ssh\" -j ACCEPT\nCOMMIT\n",
+	}}
+	if got := changes(fake); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %+v, want %+v", got, want)
+	}
+
+	exists, err := NameExistsWithError("web")
+	if exists || err != nil {
+		t.Errorf("got exists %v error %v, want false and no error", exists, err)
+	}
+}